@@ -57,7 +57,7 @@ func main() {
 		for {
 			select {
 			case newCfg := <-changes:
-				log.Printf("Config updated: %+v", newCfg)
+				log.Printf("Config updated: %+v", gorealconf.Redacted(newCfg))
 			case <-ctx.Done():
 				return
 			}
@@ -106,7 +106,7 @@ func main() {
 	if err := cfg.Load(ctx); err != nil {
 		log.Fatal("Failed to load configuration:", err)
 	}
-	log.Printf("Initial configuration loaded: %+v", cfg.Get(ctx))
+	log.Printf("Initial configuration loaded: %+v", gorealconf.Redacted(cfg.Get(ctx)))
 
 	// Simulate configuration changes
 	go func() {
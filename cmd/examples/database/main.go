@@ -13,7 +13,7 @@ type DBConfig struct {
 	Host         string        `json:"host"`
 	Port         int           `json:"port"`
 	User         string        `json:"user"`
-	Password     string        `json:"password"`
+	Password     string        `json:"password" secret:"true"`
 	Database     string        `json:"database"`
 	MaxOpenConns int           `json:"max_open_conns"`
 	MaxIdleConns int           `json:"max_idle_conns"`
@@ -30,7 +30,7 @@ func main() {
 	changes, _ := cfg.Watch(context.Background())
 	go func() {
 		for newCfg := range changes {
-			log.Printf("Database config updated: %+v", newCfg)
+			log.Printf("Database config updated: %+v", gorealconf.Redacted(newCfg))
 			updateDBConnection(newCfg)
 		}
 	}()
@@ -50,7 +50,7 @@ func main() {
 
 	go func() {
 		for newCfg := range changes {
-			log.Printf("Database config updated: %+v", newCfg)
+			log.Printf("Database config updated: %+v", gorealconf.Redacted(newCfg))
 			if err := updateDatabase(newCfg); err != nil {
 				log.Printf("Error updating database: %v", err)
 			}
@@ -42,7 +42,7 @@ func main() {
 	go func() {
 		for newCfg := range changes {
 			if isEnabled(newCfg) {
-				log.Printf("Applying feature config: %+v", newCfg)
+				log.Printf("Applying feature config: %+v", gorealconf.Redacted(newCfg))
 				if err := applyFeature(newCfg); err != nil {
 					log.Printf("Error applying feature: %v", err)
 				}
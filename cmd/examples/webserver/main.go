@@ -38,7 +38,7 @@ func main() {
 	changes, _ := cfg.Watch(context.Background())
 	go func() {
 		for newCfg := range changes {
-			log.Printf("Server config updated: %+v", newCfg)
+			log.Printf("Server config updated: %+v", gorealconf.Redacted(newCfg))
 			// In a real application, you might want to gracefully restart the server
 		}
 	}()
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// newFlags returns a flag set for a command that prints its errors and
+// usage to the command's stderr
+func newFlags(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: gorealconf %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args and checks the number of positional arguments
+func parseFlags(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// load reads and decodes the document stored in spec
+func load(ctx context.Context, spec, format string) (any, string, error) {
+	src, err := openSource(spec)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+
+	data, err := src.raw.LoadRaw(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load %s: %w", src.name, err)
+	}
+	if format == "" {
+		format = src.format
+	}
+	return decode(data, format)
+}
+
+// write writes doc to w in format
+func write(w io.Writer, doc any, format string) error {
+	data, err := encode(doc, format)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	fs := newFlags(e, "get", "<source>")
+	input := fs.String("i", "", "input `format`: json, yaml or toml (default: detected)")
+	output := fs.String("o", "", "output `format`: json, yaml or toml (default: the input format)")
+	showVersion := fs.Bool("show-version", false, "print the stored version to stderr")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	src, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var data []byte
+	var version string
+	if cas, ok := src.raw.(gorealconf.CASSource); ok && *showVersion {
+		data, version, err = cas.LoadVersion(ctx)
+	} else if *showVersion {
+		return fmt.Errorf("%s does not support versions", src.name)
+	} else {
+		data, err = src.raw.LoadRaw(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", src.name, err)
+	}
+
+	format := *input
+	if format == "" {
+		format = src.format
+	}
+	doc, format, err := decode(data, format)
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		format = *output
+	}
+
+	if *showVersion {
+		fmt.Fprintf(e.stderr, "version: %s\n", version)
+	}
+	return write(e.stdout, doc, format)
+}
+
+func runSet(ctx context.Context, e *env, args []string) error {
+	fs := newFlags(e, "set", "<source>")
+	file := fs.String("f", "-", "`file` to publish, or - for stdin")
+	input := fs.String("i", "", "input `format`: json, yaml or toml (default: detected)")
+	output := fs.String("format", "", "stored `format`: json, yaml or toml (default: implied by the key, else the input format)")
+	ifVersion := fs.String("if-version", "", "write only if the stored `version` matches; use none when the key must not exist (default: the version stored now, so the write replaces any change made since you read it)")
+	schema := fs.String("schema", "", "JSON Schema `file` the document must satisfy")
+	pluginPath := fs.String("plugin", "", "Go plugin `file` exporting "+PluginSymbol+"(data []byte) error")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	validators, err := loadValidators(*schema, *pluginPath)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if *file == "-" {
+		data, err = io.ReadAll(e.stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+		if *input == "" {
+			*input = formatOf(*file)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	doc, inputFormat, err := decode(data, *input)
+	if err != nil {
+		return err
+	}
+	if err := validate(validators, doc); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	src, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	cas, ok := src.raw.(gorealconf.CASSource)
+	if !ok {
+		return fmt.Errorf("%s does not support writes", src.name)
+	}
+
+	format := *output
+	if format == "" {
+		format = src.format
+	}
+	if format == "" {
+		format = inputFormat
+	}
+	payload, err := encode(doc, format)
+	if err != nil {
+		return err
+	}
+
+	version := *ifVersion
+	switch version {
+	case "":
+		// Last writer wins: the swap only guards the moment between this
+		// read and the write, so say what is being replaced
+		if _, version, err = cas.LoadVersion(ctx); err != nil {
+			return fmt.Errorf("failed to load %s: %w", src.name, err)
+		}
+		replaced := version
+		if replaced == "" {
+			replaced = "none"
+		}
+		fmt.Fprintf(e.stderr, "gorealconf set: no -if-version, replacing version %s\n", replaced)
+	case "none":
+		version = ""
+	}
+
+	newVersion, err := cas.CompareAndSwap(ctx, version, payload)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, newVersion)
+	return nil
+}
+
+func runValidate(ctx context.Context, e *env, args []string) error {
+	fs := newFlags(e, "validate", "<file>")
+	input := fs.String("i", "", "input `format`: json, yaml or toml (default: detected)")
+	schema := fs.String("schema", "", "JSON Schema `file` the document must satisfy")
+	pluginPath := fs.String("plugin", "", "Go plugin `file` exporting "+PluginSymbol+"(data []byte) error")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if *schema == "" && *pluginPath == "" {
+		fmt.Fprintln(e.stderr, "gorealconf validate: -schema or -plugin is required")
+		return errUsage
+	}
+
+	validators, err := loadValidators(*schema, *pluginPath)
+	if err != nil {
+		return err
+	}
+	doc, _, err := load(ctx, fs.Arg(0), *input)
+	if err != nil {
+		return err
+	}
+	if err := validate(validators, doc); err != nil {
+		return fmt.Errorf("%s is invalid: %w", label(fs.Arg(0)), err)
+	}
+
+	fmt.Fprintf(e.stdout, "%s is valid\n", label(fs.Arg(0)))
+	return nil
+}
+
+// runDiff exits with exitIdentical, exitDifferent or, for any failure,
+// exitTrouble
+func runDiff(ctx context.Context, e *env, args []string) error {
+	fs := newFlags(e, "diff", "<source> <source>")
+	input := fs.String("i", "", "input `format` of both sources: json, yaml or toml (default: detected)")
+	output := fs.String("o", "text", "output `format`: text, a unified diff of changed fields, or json")
+	secretKeys := fs.String("secret-keys", strings.Join(gorealconf.DefaultSecretKeys, ","), "comma-separated field `names` whose values are masked")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return &exitError{code: exitTrouble, err: err}
+	}
+	if *output != "text" && *output != formatJSON {
+		return &exitError{code: exitTrouble, err: fmt.Errorf("unknown output format %q, expected text or json", *output)}
+	}
+
+	report, err := diff(ctx, fs.Arg(0), fs.Arg(1), *input, *secretKeys)
+	if err != nil {
+		return &exitError{code: exitTrouble, err: err}
+	}
+
+	if *output == formatJSON {
+		err = report.WriteJSON(e.stdout)
+	} else {
+		err = report.WriteText(e.stdout)
+	}
+	if err != nil {
+		return &exitError{code: exitTrouble, err: err}
+	}
+	if !report.Identical() {
+		return &exitError{code: exitDifferent}
+	}
+	return nil
+}
+
+// diff compares the documents stored in two sources. Unlike the sources
+// themselves, the report's labels never include credentials.
+func diff(ctx context.Context, from, to, format, secretKeys string) (*gorealconf.DiffReport, error) {
+	old, _, err := load(ctx, from, format)
+	if err != nil {
+		return nil, err
+	}
+	next, _, err := load(ctx, to, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, key := range strings.Split(secretKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	changes, err := gorealconf.Diff(old, next, gorealconf.WithSecretKeys(keys...))
+	if err != nil {
+		return nil, err
+	}
+	return &gorealconf.DiffReport{From: label(from), To: label(to), Changes: changes}, nil
+}
+
+// label names a source in output and errors, without the password of a
+// URL
+func label(spec string) string {
+	u, err := url.Parse(spec)
+	if err == nil {
+		if u.User != nil {
+			return u.Redacted()
+		}
+		return spec
+	}
+	// A URL that does not parse may still carry a password
+	if scheme, rest, ok := strings.Cut(spec, "://"); ok {
+		if i := strings.LastIndex(rest, "@"); i >= 0 {
+			return scheme + "://xxxxx@" + rest[i+1:]
+		}
+	}
+	return spec
+}
+
+func runWatch(ctx context.Context, e *env, args []string) error {
+	fs := newFlags(e, "watch", "<source>")
+	input := fs.String("i", "", "input `format`: json, yaml or toml (default: detected)")
+	output := fs.String("o", "", "output `format`: json, yaml or toml (default: the input format)")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	src, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	format := *input
+	if format == "" {
+		format = src.format
+	}
+	show := func(data []byte) error {
+		doc, inputFormat, err := decode(data, format)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "gorealconf watch: %v\n", err)
+			return nil
+		}
+		out := *output
+		if out == "" {
+			out = inputFormat
+		}
+		fmt.Fprintf(e.stdout, "# %s\n", time.Now().Format(time.RFC3339))
+		return write(e.stdout, doc, out)
+	}
+
+	data, err := src.raw.LoadRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", src.name, err)
+	}
+	if err := show(data); err != nil {
+		return err
+	}
+
+	updates, err := src.raw.WatchRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", src.name, err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case data, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := show(data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func runHistory(ctx context.Context, e *env, args []string) error {
+	fs := newFlags(e, "history", "<source>")
+	limit := fs.Int("n", 10, "number of versions to list, or 0 for all")
+	output := fs.String("o", "", "output `format` for -values: json, yaml or toml (default: the stored format)")
+	values := fs.Bool("values", false, "print the configuration of each version")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	src, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	history, ok := src.raw.(gorealconf.HistorySource)
+	if !ok {
+		return fmt.Errorf("%s does not retain history", src.name)
+	}
+	revisions, err := history.LoadHistory(ctx, *limit)
+	if err != nil {
+		return fmt.Errorf("failed to load history of %s: %w", src.name, err)
+	}
+
+	for _, rev := range revisions {
+		modified := "-"
+		if !rev.ModifiedAt.IsZero() {
+			modified = rev.ModifiedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(e.stdout, "%s\t%s\n", rev.Version, modified)
+		if !*values {
+			continue
+		}
+
+		doc, format, err := decode(rev.Data, src.format)
+		if err != nil {
+			return fmt.Errorf("version %s: %w", rev.Version, err)
+		}
+		if *output != "" {
+			format = *output
+		}
+		if err := write(e.stdout, doc, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// Document formats, selected with -i and -o or by file extension
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+)
+
+func codecFor(format string) (gorealconf.Codec, error) {
+	switch format {
+	case formatJSON:
+		return gorealconf.JSONCodec{}, nil
+	case formatYAML:
+		return gorealconf.YAMLCodec{}, nil
+	case formatTOML:
+		return gorealconf.TOMLCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected json, yaml or toml", format)
+}
+
+// formatOf returns the format implied by the extension of name, if any
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return formatJSON
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	}
+	return ""
+}
+
+// decode parses a document in format, or detects the format when it is
+// empty: JSON when the document starts with a brace or bracket, then TOML,
+// then YAML.
+func decode(data []byte, format string) (any, string, error) {
+	if format == "" {
+		trimmed := bytes.TrimSpace(data)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			format = formatJSON
+		} else if doc, err := decodeAs(data, formatTOML); err == nil {
+			return doc, formatTOML, nil
+		} else {
+			format = formatYAML
+		}
+	}
+
+	doc, err := decodeAs(data, format)
+	return doc, format, err
+}
+
+func decodeAs(data []byte, format string) (any, error) {
+	codec, err := codecFor(format)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := codec.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", format, err)
+	}
+	return doc, nil
+}
+
+// encode prints doc in format, indented for reading
+func encode(doc any, format string) ([]byte, error) {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case formatTOML:
+		if _, ok := doc.(map[string]any); !ok {
+			return nil, fmt.Errorf("toml documents must be tables, got %T", doc)
+		}
+	}
+
+	codec, err := codecFor(format)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Marshal(doc)
+}
+
+// normalize converts doc to the types encoding/json produces, so
+// documents decoded from different formats compare and validate alike
+func normalize(doc any) (any, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,152 @@
+// Command gorealconf inspects, validates and publishes configurations in
+// the stores gorealconf reads from.
+//
+// Usage:
+//
+//	gorealconf get [-o format] [-show-version] <source>
+//	gorealconf set [-f file] [-i format] [-if-version version] [-schema file] [-plugin file] <source>
+//	gorealconf publish ...                   same as set
+//	gorealconf validate [-schema file] [-plugin file] [-i format] <file>
+//	gorealconf diff [-i format] [-o text|json] <source> <source>
+//	gorealconf watch [-o format] <source>
+//	gorealconf history [-n count] [-o format] [-values] <source>
+//
+// Sources are file paths or URLs:
+//
+//	path/to/config.yaml or file:path/to/config.yaml
+//	etcd://host:2379[,host:2379]/key
+//	consul://host:8500/key
+//	redis://[:password@]host:6379/key[?channel=name]
+//	http(s)://host/path
+//
+// Formats are json, yaml and toml. Input formats default to the extension
+// of the file or key and are detected otherwise; output defaults to the
+// input format.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// Exit codes
+const (
+	exitOK       = 0
+	exitFailure  = 1 // an operation failed or a document is invalid
+	exitUsage    = 2 // invalid arguments
+	exitConflict = 3 // set lost a compare-and-swap race
+)
+
+// Exit codes of diff, which follow diff(1) so they can gate CI jobs
+const (
+	exitIdentical = 0
+	exitDifferent = 1
+	exitTrouble   = 2
+)
+
+// errUsage reports invalid arguments; the command prints its usage
+var errUsage = errors.New("invalid usage")
+
+// exitError ends a command with a specific exit code
+type exitError struct {
+	code int
+	err  error // printed when not nil
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+// env holds the streams a command reads and writes
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands = []command{
+	{"get", "print the configuration stored in a source", runGet},
+	{"set", "validate and write a configuration with compare-and-swap", runSet},
+	{"publish", "same as set", runSet},
+	{"validate", "check a file against a JSON Schema or a plugin validator", runValidate},
+	{"diff", "compare the configurations of two sources", runDiff},
+	{"watch", "print a source's configuration whenever it changes", runWatch},
+	{"history", "list the versions a source retains", runHistory},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+// run executes the command line args and returns the exit code
+func run(ctx context.Context, args []string, e *env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(e.stderr)
+		return exitUsage
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(ctx, e, args[1:])
+		var conflict *gorealconf.CASConflictError
+		var exit *exitError
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.As(err, &exit):
+			if exit.err != nil && !errors.Is(exit.err, errUsage) {
+				fmt.Fprintf(e.stderr, "gorealconf %s: %v\n", cmd.name, exit.err)
+			}
+			return exit.code
+		case errors.Is(err, errUsage):
+			return exitUsage
+		case errors.As(err, &conflict):
+			fmt.Fprintf(e.stderr, "gorealconf %s: %v\n", cmd.name, err)
+			return exitConflict
+		default:
+			fmt.Fprintf(e.stderr, "gorealconf %s: %v\n", cmd.name, err)
+			return exitFailure
+		}
+	}
+
+	fmt.Fprintf(e.stderr, "gorealconf: unknown command %q\n", args[0])
+	usage(e.stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gorealconf <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run gorealconf <command> -h for the flags of a command.")
+}
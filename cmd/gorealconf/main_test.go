@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// runCommand runs args and returns the exit code, stdout and stderr
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &env{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+	})
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := writeFile(t, dir, "config.yaml", "name: app\nport: 8080\n")
+	tomlPath := writeFile(t, dir, "config.toml", "name = \"app\"\nport = 9090\n")
+	schemaPath := writeFile(t, dir, "schema.json", `{
+		"type": "object",
+		"required": ["name", "port"],
+		"properties": {"port": {"type": "integer", "maximum": 65535}}
+	}`)
+
+	t.Run("usage", func(t *testing.T) {
+		tests := [][]string{
+			nil,
+			{"unknown"},
+			{"get"},
+			{"diff", yamlPath},
+			{"validate", yamlPath},
+		}
+		for _, args := range tests {
+			if code, _, _ := runCommand(t, "", args...); code != exitUsage {
+				t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+			}
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		tests := []struct {
+			name string
+			args []string
+			want string
+		}{
+			{"yaml as json", []string{"get", "-o", "json", yamlPath}, "{\n  \"name\": \"app\",\n  \"port\": 8080\n}\n"},
+			{"toml as yaml", []string{"get", "-o", "yaml", tomlPath}, "name: app\nport: 9090\n"},
+			{"input format", []string{"get", yamlPath}, "name: app\nport: 8080\n"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, stdout, stderr := runCommand(t, "", tt.args...)
+				if code != exitOK {
+					t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+				}
+				if stdout != tt.want {
+					t.Errorf("expected %q, got %q", tt.want, stdout)
+				}
+			})
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		target := filepath.Join(dir, "published.json")
+
+		code, stdout, stderr := runCommand(t, "name: app\nport: 80\n", "set", "-if-version", "none", target)
+		if code != exitOK {
+			t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+		}
+		version := strings.TrimSpace(stdout)
+		if got, _ := os.ReadFile(target); string(got) != "{\n  \"name\": \"app\",\n  \"port\": 80\n}\n" {
+			t.Errorf("unexpected content %q", got)
+		}
+
+		if code, _, _ := runCommand(t, `{"name":"app","port":81}`, "set", "-if-version", "none", target); code != exitConflict {
+			t.Errorf("expected exit code %d for a stale version, got %d", exitConflict, code)
+		}
+		if code, _, _ := runCommand(t, `{"name":"app","port":70000}`, "set", "-schema", schemaPath, target); code != exitFailure {
+			t.Errorf("expected exit code %d for an invalid document, got %d", exitFailure, code)
+		}
+		code, stdout, stderr = runCommand(t, `{"name":"app","port":81}`, "publish", "-if-version", version, "-schema", schemaPath, target)
+		if code != exitOK {
+			t.Errorf("expected exit code 0, got %d: %s", code, stderr)
+		}
+		version = strings.TrimSpace(stdout)
+
+		// Without -if-version the write replaces the stored version and
+		// says so
+		code, _, stderr = runCommand(t, `{"name":"app","port":82}`, "set", target)
+		if code != exitOK {
+			t.Errorf("expected exit code 0, got %d: %s", code, stderr)
+		}
+		if !strings.Contains(stderr, "replacing version "+version) {
+			t.Errorf("expected the replaced version %s in %q", version, stderr)
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		invalidPath := writeFile(t, dir, "invalid.yaml", "name: app\nport: 70000\n")
+
+		if code, _, stderr := runCommand(t, "", "validate", "-schema", schemaPath, yamlPath); code != exitOK {
+			t.Errorf("expected exit code 0, got %d: %s", code, stderr)
+		}
+		code, _, stderr := runCommand(t, "", "validate", "-schema", schemaPath, invalidPath)
+		if code != exitFailure {
+			t.Errorf("expected exit code %d, got %d", exitFailure, code)
+		}
+		if !strings.Contains(stderr, "maximum") {
+			t.Errorf("expected the schema violation in %q", stderr)
+		}
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		secretPath := writeFile(t, dir, "secret.json", `{"name":"app","port":9090,"db_password":"hunter2"}`)
+		rotatedPath := writeFile(t, dir, "rotated.json", `{"name":"app","port":9090,"db_password":"hunter3"}`)
+
+		tests := []struct {
+			name string
+			args []string
+			code int
+			want string
+		}{
+			{
+				"identical",
+				[]string{"diff", yamlPath, yamlPath},
+				exitIdentical,
+				"",
+			},
+			{
+				"text",
+				[]string{"diff", yamlPath, tomlPath},
+				exitDifferent,
+				"--- " + yamlPath + "\n+++ " + tomlPath + "\n@@ port @@\n-8080\n+9090\n",
+			},
+			{
+				"json with secrets",
+				[]string{"diff", "-o", "json", tomlPath, secretPath},
+				exitDifferent,
+				`"changes": [
+    {
+      "path": "db_password",
+      "new": "[REDACTED]"
+    }
+  ]`,
+			},
+			{
+				"changed secret",
+				[]string{"diff", secretPath, rotatedPath},
+				exitDifferent,
+				"@@ db_password @@\n-\"[REDACTED]\"\n+\"[REDACTED]\"\n",
+			},
+			{
+				"missing source",
+				[]string{"diff", yamlPath, filepath.Join(dir, "missing.json")},
+				exitTrouble,
+				"",
+			},
+			{
+				"unknown output format",
+				[]string{"diff", "-o", "yaml", yamlPath, tomlPath},
+				exitTrouble,
+				"",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, stdout, stderr := runCommand(t, "", tt.args...)
+				if code != tt.code {
+					t.Fatalf("expected exit code %d, got %d: %s", tt.code, code, stderr)
+				}
+				if !strings.Contains(stdout, tt.want) {
+					t.Errorf("expected %q in %q", tt.want, stdout)
+				}
+				if strings.Contains(stdout, "hunter") {
+					t.Errorf("secret leaked in %q", stdout)
+				}
+			})
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		type TestConfig struct {
+			Port int `json:"port"`
+		}
+		ctx := context.Background()
+		cfg := gorealconf.New[TestConfig](gorealconf.WithHistory[TestConfig](10))
+		for _, port := range []int{80, 81} {
+			if err := cfg.Update(ctx, TestConfig{Port: port}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		server := httptest.NewServer(http.StripPrefix("/admin", gorealconf.NewAdminHandler(cfg)))
+		defer server.Close()
+
+		code, stdout, stderr := runCommand(t, "", "history", "-values", "-o", "json", server.URL+"/admin/config")
+		if code != exitOK {
+			t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+		}
+		lines := strings.Split(stdout, "\n")
+		if !strings.HasPrefix(lines[0], "2\t") || !strings.Contains(stdout, `"port": 81`) {
+			t.Errorf("expected version 2 first, got %q", stdout)
+		}
+		if !strings.Contains(stdout, `"port": 80`) {
+			t.Errorf("expected version 1 in %q", stdout)
+		}
+
+		if code, _, _ := runCommand(t, "", "history", yamlPath); code != exitFailure {
+			t.Errorf("expected exit code %d for a source without history, got %d", exitFailure, code)
+		}
+	})
+	t.Run("credentials", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+		withPassword := strings.Replace(server.URL, "http://", "http://user:s3cr3t@", 1) + "/config"
+
+		tests := [][]string{
+			{"get", "redis://:s3cr3t@localhost:6379/%zz"},
+			{"get", "redis://:s3cr3t@localhost:6379"},
+			{"get", "-show-version", withPassword},
+			{"get", withPassword},
+			{"history", "redis://:s3cr3t@localhost:6379/key%"},
+			{"validate", "-schema", schemaPath, withPassword},
+		}
+		for _, args := range tests {
+			code, _, stderr := runCommand(t, "", args...)
+			if code != exitFailure {
+				t.Errorf("%v: expected exit code %d, got %d: %s", args, exitFailure, code, stderr)
+			}
+			if strings.Contains(stderr, "s3cr3t") {
+				t.Errorf("%v: error exposes the password: %s", args, stderr)
+			}
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// source is a configuration store named on the command line:
+//
+//	path/to/config.yaml or file:path/to/config.yaml
+//	etcd://host:2379[,host:2379]/key
+//	consul://host:8500/key
+//	redis://[:password@]host:6379/key[?channel=name]
+//	http(s)://host/path
+type source struct {
+	name   string // the spec without its password, for messages
+	raw    gorealconf.RawSource
+	format string // implied by the file name or key, if any
+}
+
+func openSource(spec string) (*source, error) {
+	if !strings.Contains(spec, "://") {
+		path := strings.TrimPrefix(spec, "file:")
+		file, err := gorealconf.NewFileSource[any](path)
+		if err != nil {
+			return nil, err
+		}
+		return &source{name: label(spec), raw: file, format: formatOf(path)}, nil
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		// The parse error repeats the URL; keep only its reason
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return nil, fmt.Errorf("invalid source %q: %w", label(spec), err)
+	}
+	key := u.Path
+	if (key == "" || key == "/") && u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("source %q has no key", label(spec))
+	}
+
+	var raw gorealconf.RawSource
+	switch u.Scheme {
+	case "etcd":
+		raw, err = gorealconf.NewEtcdSource[any](strings.Split(u.Host, ","), key)
+	case "consul":
+		raw, err = gorealconf.NewConsulSource[any](u.Host, strings.TrimPrefix(key, "/"))
+	case "redis":
+		password, _ := u.User.Password()
+		key = strings.TrimPrefix(key, "/")
+		channel := u.Query().Get("channel")
+		if channel == "" {
+			channel = key
+		}
+		raw, err = gorealconf.NewRedisSource[any](u.Host, password, key, channel)
+	case "http", "https":
+		raw, err = gorealconf.NewHTTPSource[any](spec)
+	default:
+		return nil, fmt.Errorf("unsupported source scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", label(spec), err)
+	}
+
+	return &source{name: label(spec), raw: raw, format: formatOf(key)}, nil
+}
+
+func (s *source) Close() error {
+	if c, ok := s.raw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"plugin"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// PluginSymbol is the function a validator plugin exports. It receives
+// the document encoded as JSON, so the plugin can decode it into its own
+// configuration type:
+//
+//	func Validate(data []byte) error
+//
+// Build it with go build -buildmode=plugin.
+const PluginSymbol = "Validate"
+
+// validator checks documents before they are published
+type validator func(doc any) error
+
+// loadValidators returns the validators for a JSON Schema file and a Go
+// plugin, either of which may be empty
+func loadValidators(schemaPath, pluginPath string) ([]validator, error) {
+	var validators []validator
+
+	if schemaPath != "" {
+		schema, err := jsonschema.Compile(schemaPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+		validators = append(validators, func(doc any) error {
+			normalized, err := normalize(doc)
+			if err != nil {
+				return err
+			}
+			return schema.Validate(normalized)
+		})
+	}
+
+	if pluginPath != "" {
+		p, err := plugin.Open(pluginPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open plugin: %w", err)
+		}
+		symbol, err := p.Lookup(PluginSymbol)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugin: %w", err)
+		}
+		validate, ok := symbol.(func([]byte) error)
+		if !ok {
+			return nil, fmt.Errorf("invalid plugin: %s is %T, expected func([]byte) error", PluginSymbol, symbol)
+		}
+		validators = append(validators, func(doc any) error {
+			data, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			return validate(data)
+		})
+	}
+
+	return validators, nil
+}
+
+// validate runs every validator, returning the first failure
+func validate(validators []validator, doc any) error {
+	for _, v := range validators {
+		if err := v(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package gorealconf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AdminHandler exposes a Config over HTTP:
+//
+//	GET /config          current value, version in the ETag header
+//	GET /config/history  retained history, see WithHistory
+//	PUT /config          validated update, requires If-Match: "<version>" or *
+//	GET /config/watch    server-sent events, one per applied version
+//
+// Mount it under a prefix with http.StripPrefix. Every value is passed
+// through Redacted, and masked values in a PUT body keep their current
+// value. Because secrets are served masked, the endpoints are meant for
+// operators rather than as a source: an HTTPSource pointed at them would
+// load RedactedValue in place of each secret.
+//
+// With WithSignatureVerification, a PUT body must be a signed envelope
+// whose payload is encoded with the Config's codec. Unsigned or badly
+// signed bodies are refused with 403.
+type AdminHandler[T any] struct {
+	config *Config[T]
+	mux    *http.ServeMux
+}
+
+func NewAdminHandler[T any](config *Config[T]) *AdminHandler[T] {
+	h := &AdminHandler[T]{
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /config", h.getConfig)
+	h.mux.HandleFunc("PUT /config", h.putConfig)
+	h.mux.HandleFunc("GET /config/history", h.getHistory)
+	h.mux.HandleFunc("GET /config/watch", h.watchConfig)
+
+	return h
+}
+
+func (h *AdminHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler[T]) getConfig(w http.ResponseWriter, r *http.Request) {
+	value, version := h.config.snapshot()
+	etag := versionETag(version)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Config-Version", strconv.FormatUint(version, 10))
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Redacted(value))
+}
+
+func (h *AdminHandler[T]) putConfig(w http.ResponseWriter, r *http.Request) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		writeError(w, http.StatusPreconditionRequired, errors.New("If-Match header is required"))
+		return
+	}
+
+	var value T
+	if h.config.trust != nil {
+		// A Config that verifies its sources takes only signed values here
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		value, err = h.config.decode(data)
+		var serr *SignatureError
+		switch {
+		case errors.As(err, &serr):
+			writeError(w, http.StatusForbidden, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid config: %w", err))
+			return
+		}
+	} else {
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&value); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid config: %w", err))
+			return
+		}
+
+		// Keep secrets the client only saw in redacted form
+		current, _ := h.config.snapshot()
+		mergeRedacted(&value, current)
+	}
+
+	var expected *uint64
+	if match != "*" {
+		version, err := parseVersionETag(match)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		expected = &version
+	}
+
+	// Invalid values are rejected even when the Config would apply them
+	err := h.config.update(r.Context(), value, expected, SourceManual, updateStrict)
+
+	var conflict *VersionConflictError
+	switch {
+	case errors.As(err, &conflict):
+		w.Header().Set("ETag", versionETag(conflict.Actual))
+		writeError(w, http.StatusPreconditionFailed, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.getConfig(w, r)
+}
+
+func (h *AdminHandler[T]) getHistory(w http.ResponseWriter, r *http.Request) {
+	history := h.config.History()
+	for i := range history {
+		history[i].Value = Redacted(history[i].Value)
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (h *AdminHandler[T]) watchConfig(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+
+	ctx := r.Context()
+	changes, cleanup := h.config.Subscribe(ctx)
+	defer cleanup()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var sent uint64
+	if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		sent = id
+	}
+
+	send := func() error {
+		value, version := h.config.snapshot()
+		if version <= sent {
+			return nil
+		}
+		data, err := json.Marshal(Redacted(value))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", version, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		sent = version
+		return nil
+	}
+
+	if err := send(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			if err := send(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func versionETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+func parseVersionETag(etag string) (uint64, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	version, err := strconv.ParseUint(strings.Trim(etag, `"`), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid If-Match version %q", etag)
+	}
+	return version, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,212 @@
+package gorealconf
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+
+	ctx := context.Background()
+	cfg := New[TestConfig](
+		WithHistory[TestConfig](10),
+		WithRollback[TestConfig](true),
+		WithValidation[TestConfig](func(old, new TestConfig) error {
+			if new.Value == "" {
+				return errors.New("value is required")
+			}
+			return nil
+		}),
+	)
+	if err := cfg.Update(ctx, TestConfig{Value: "v1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := httptest.NewServer(http.StripPrefix("/admin", NewAdminHandler(cfg)))
+	defer server.Close()
+
+	put := func(ifMatch, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/admin/config", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("put", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			ifMatch string
+			body    string
+			want    int
+		}{
+			{"missing If-Match", "", `{"value":"v2"}`, http.StatusPreconditionRequired},
+			{"stale version", `"0"`, `{"value":"v2"}`, http.StatusPreconditionFailed},
+			{"invalid value", `"1"`, `{"value":""}`, http.StatusUnprocessableEntity},
+			{"unknown field", `"1"`, `{"other":"v2"}`, http.StatusBadRequest},
+			{"current version", `"1"`, `{"value":"v2"}`, http.StatusOK},
+		}
+		for _, tt := range tests {
+			if resp := put(tt.ifMatch, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, resp.StatusCode)
+			}
+		}
+
+		if got := cfg.Get(ctx); got.Value != "v2" {
+			t.Errorf("expected v2, got %q", got.Value)
+		}
+		if got := len(cfg.History()); got != 2 {
+			t.Errorf("expected 2 history entries, got %d", got)
+		}
+	})
+
+	t.Run("put without rollback", func(t *testing.T) {
+		cfg := New[TestConfig](WithValidation[TestConfig](func(old, new TestConfig) error {
+			if new.Value == "" {
+				return errors.New("value is required")
+			}
+			return nil
+		}))
+		if err := cfg.Update(ctx, TestConfig{Value: "v1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		server := httptest.NewServer(NewAdminHandler(cfg))
+		defer server.Close()
+
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/config", strings.NewReader(`{"value":""}`))
+		req.Header.Set("If-Match", "*")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status 422, got %d", resp.StatusCode)
+		}
+		if got := cfg.Get(ctx); got.Value != "v1" {
+			t.Errorf("expected the invalid value to be rejected, got %q", got.Value)
+		}
+	})
+
+	t.Run("put with signature verification", func(t *testing.T) {
+		pub, key, _ := ed25519.GenerateKey(nil)
+		_, untrustedKey, _ := ed25519.GenerateKey(nil)
+		trust := NewTrustStore()
+		if err := trust.AddKey("ops", pub, time.Time{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cfg := New[TestConfig](WithSignatureVerification[TestConfig](trust))
+		server := httptest.NewServer(NewAdminHandler(cfg))
+		defer server.Close()
+
+		sign := func(signer *Signer, value string) string {
+			envelope, err := signer.Sign([]byte(`{"value":"` + value + `"}`))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return string(envelope)
+		}
+		tests := []struct {
+			name string
+			body string
+			want int
+		}{
+			{"unsigned", `{"value":"unsigned"}`, http.StatusForbidden},
+			{"untrusted key", sign(NewSigner("ops", untrustedKey), "untrusted"), http.StatusForbidden},
+			{"signed", sign(NewSigner("ops", key), "signed"), http.StatusOK},
+		}
+		for _, tt := range tests {
+			req, _ := http.NewRequest(http.MethodPut, server.URL+"/config", strings.NewReader(tt.body))
+			req.Header.Set("If-Match", "*")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, resp.StatusCode)
+			}
+		}
+
+		if got := cfg.Get(ctx); got.Value != "signed" {
+			t.Errorf("expected only the signed value to apply, got %q", got.Value)
+		}
+	})
+
+	t.Run("history with HTTPSource", func(t *testing.T) {
+		source, err := NewHTTPSource[TestConfig](server.URL + "/admin/config")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		revisions, err := source.LoadHistory(ctx, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		history := cfg.History()
+		if len(revisions) != 2 {
+			t.Fatalf("expected 2 revisions, got %d", len(revisions))
+		}
+		for i, rev := range revisions {
+			entry := history[len(history)-1-i]
+			if rev.Version != strconv.FormatUint(entry.Version, 10) || !rev.ModifiedAt.Equal(entry.AppliedAt) {
+				t.Errorf("revision %d: expected version %d, got %+v", i, entry.Version, rev)
+			}
+			if want := `{"value":"` + entry.Value.Value + `"}`; string(rev.Data) != want {
+				t.Errorf("revision %d: expected %s, got %s", i, want, rev.Data)
+			}
+		}
+	})
+
+	t.Run("watch with HTTPSource", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		source, err := NewHTTPSource[TestConfig](server.URL+"/admin/config/watch", WithHTTPWatchMode(HTTPServerSentEvents))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		changes, err := source.Watch(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		select {
+		case value := <-changes:
+			if value.Value != "v2" {
+				t.Errorf("expected v2, got %q", value.Value)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for event")
+		}
+
+		if resp := put("*", `{"value":"v3"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		select {
+		case value := <-changes:
+			if value.Value != "v3" {
+				t.Errorf("expected v3, got %q", value.Value)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for event")
+		}
+	})
+}
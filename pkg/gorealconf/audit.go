@@ -0,0 +1,254 @@
+package gorealconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditOutcome is what happened to an audited change
+type AuditOutcome string
+
+const (
+	AuditApplied    AuditOutcome = "applied"     // the change became the current value
+	AuditRejected   AuditOutcome = "rejected"    // the change was refused, for example by a freeze or a version conflict
+	AuditRolledBack AuditOutcome = "rolled_back" // the change failed validation or a rollout and the previous value was kept
+)
+
+// AuditEvent records a change to a configuration. Changed secrets are
+// listed with masked values, so events never contain secrets.
+type AuditEvent struct {
+	Time    time.Time    `json:"time"`
+	Config  string       `json:"config,omitempty"`
+	Actor   string       `json:"actor,omitempty"`
+	Source  string       `json:"source"`
+	Outcome AuditOutcome `json:"outcome"`
+	// Version is the version the change was applied as, or the current
+	// version when it was not applied
+	Version    uint64           `json:"version"`
+	Changes    []Change         `json:"changes"`
+	Validation *AuditValidation `json:"validation,omitempty"`
+	// Reason explains why the change was rejected or rolled back
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuditValidation is the validation outcome of an audited change. It is
+// absent when the change was not validated.
+type AuditValidation struct {
+	Passed bool     `json:"passed"`
+	Rules  []string `json:"rules,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// AuditSink stores audit events. Sinks are called synchronously from the
+// update path, one event at a time and in version order, so they should
+// not block for long.
+type AuditSink interface {
+	Audit(ctx context.Context, event AuditEvent) error
+}
+
+// WithAuditSink reports every applied, rejected and rolled back change to
+// sinks. Failing sinks are logged and do not affect the update.
+// Updates of an audited Config are serialized, so a slow sink delays the
+// updates that follow it.
+func WithAuditSink[T any](sinks ...AuditSink) Option[T] {
+	return func(c *Config[T]) {
+		c.auditSinks = append(c.auditSinks, sinks...)
+	}
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx attributing changes made with it to
+// actor, such as an authenticated user or service. Audit events record it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx, if any
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// audit completes event with the change from previous to next and reports
+// it to the audit sinks
+func (c *Config[T]) audit(ctx context.Context, event AuditEvent, previous, next T) {
+	if len(c.auditSinks) == 0 {
+		return
+	}
+
+	event.Time = time.Now().UTC()
+	event.Config = c.name
+	event.Actor, _ = ActorFromContext(ctx)
+
+	logger := c.log(event.Source).With(LogKeyVersion, event.Version)
+	changes, err := Diff(previous, next)
+	if err != nil {
+		logger.Error("audit diff failed", "error", err)
+	}
+	event.Changes = changes
+
+	// Record the change even when the caller gives up on it
+	ctx = context.WithoutCancel(ctx)
+	for _, sink := range c.auditSinks {
+		if err := sink.Audit(ctx, event); err != nil {
+			logger.Error("audit sink failed", "outcome", event.Outcome, "error", withoutURL(err))
+		}
+	}
+}
+
+// validationOutcome describes the result of a validator for audit events
+func validationOutcome(err error) *AuditValidation {
+	if err == nil {
+		return &AuditValidation{Passed: true}
+	}
+	return &AuditValidation{Rules: ruleNames(err), Error: err.Error()}
+}
+
+// MemoryAuditSink keeps audit events in memory, for tests and for
+// exposing recent changes
+type MemoryAuditSink struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func NewMemoryAuditSink() *MemoryAuditSink {
+	return &MemoryAuditSink{}
+}
+
+func (s *MemoryAuditSink) Audit(ctx context.Context, event AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Events returns the recorded events, oldest first
+func (s *MemoryAuditSink) Events() []AuditEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]AuditEvent, len(s.events))
+	copy(events, s.events)
+	return events
+}
+
+// FileAuditSink appends audit events to a file as JSON lines
+type FileAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditSink opens path for appending, creating it readable by the
+// owner only
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{file: file}, nil
+}
+
+// Audit writes event as a single line and syncs the file, so recorded
+// events survive a crash
+func (s *FileAuditSink) Audit(ctx context.Context, event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(data); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+type webhookAuditOptions struct {
+	client *http.Client
+	header http.Header
+}
+
+// WebhookAuditOption configures a WebhookAuditSink
+type WebhookAuditOption func(*webhookAuditOptions)
+
+// WithAuditHTTPClient sends events with client instead of a client with a
+// 10 second timeout
+func WithAuditHTTPClient(client *http.Client) WebhookAuditOption {
+	return func(o *webhookAuditOptions) {
+		o.client = client
+	}
+}
+
+// WithAuditHeader sets a header on every request, such as Authorization
+func WithAuditHeader(key, value string) WebhookAuditOption {
+	return func(o *webhookAuditOptions) {
+		o.header.Set(key, value)
+	}
+}
+
+// WebhookAuditSink POSTs each audit event as JSON to a URL
+type WebhookAuditSink struct {
+	url    string
+	client *http.Client
+	header http.Header
+}
+
+func NewWebhookAuditSink(url string, opts ...WebhookAuditOption) *WebhookAuditSink {
+	options := webhookAuditOptions{
+		client: &http.Client{Timeout: 10 * time.Second},
+		header: make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &WebhookAuditSink{
+		url:    url,
+		client: options.client,
+		header: options.header,
+	}
+}
+
+// Audit posts event and fails unless the endpoint answers with a 2xx
+// status
+func (s *WebhookAuditSink) Audit(ctx context.Context, event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, values := range s.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package gorealconf
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	type TestConfig struct {
+		Value    string `json:"value"`
+		Password string `json:"password" secret:"true"`
+		MaxConns int    `json:"max_conns"`
+	}
+
+	ctx := WithActor(context.Background(), "alice")
+
+	sink := NewMemoryAuditSink()
+	cfg := New[TestConfig](
+		WithName[TestConfig]("app"),
+		WithAuditSink[TestConfig](sink),
+		WithRollback[TestConfig](true),
+		WithValidationRules(ValidationRule[TestConfig]{Name: "max_conns_positive", Check: func(_, new TestConfig) error {
+			if new.MaxConns <= 0 {
+				return errors.New("max_conns must be positive")
+			}
+			return nil
+		}}),
+	)
+
+	if err := cfg.Update(ctx, TestConfig{Value: "a", Password: "hunter2", MaxConns: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.Update(ctx, TestConfig{Value: "b", Password: "hunter3", MaxConns: 0}); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if err := cfg.UpdateIfVersion(context.Background(), 0, TestConfig{Value: "c", MaxConns: 1}); err == nil {
+		t.Fatal("expected version conflict")
+	}
+
+	events := sink.Events()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	t.Run("applied", func(t *testing.T) {
+		e := events[0]
+		if e.Outcome != AuditApplied || e.Version != 1 || e.Actor != "alice" || e.Config != "app" || e.Source != SourceManual {
+			t.Errorf("unexpected event %+v", e)
+		}
+		if e.Validation == nil || !e.Validation.Passed {
+			t.Errorf("expected validation to pass, got %+v", e.Validation)
+		}
+		// A changed secret is recorded without its values
+		want := []Change{
+			{Path: "max_conns", Old: float64(0), New: float64(1)},
+			{Path: "password", Old: RedactedValue, New: RedactedValue},
+			{Path: "value", Old: "", New: "a"},
+		}
+		if !reflect.DeepEqual(e.Changes, want) {
+			t.Errorf("expected changes %+v, got %+v", want, e.Changes)
+		}
+	})
+
+	t.Run("rolled back", func(t *testing.T) {
+		e := events[1]
+		if e.Outcome != AuditRolledBack || e.Version != 1 {
+			t.Errorf("unexpected event %+v", e)
+		}
+		if e.Validation == nil || e.Validation.Passed || !reflect.DeepEqual(e.Validation.Rules, []string{"max_conns_positive"}) {
+			t.Errorf("unexpected validation %+v", e.Validation)
+		}
+		want := []Change{
+			{Path: "max_conns", Old: float64(1), New: float64(0)},
+			{Path: "password", Old: RedactedValue, New: RedactedValue},
+			{Path: "value", Old: "a", New: "b"},
+		}
+		if !reflect.DeepEqual(e.Changes, want) {
+			t.Errorf("expected changes %+v, got %+v", want, e.Changes)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		e := events[2]
+		if e.Outcome != AuditRejected || e.Version != 1 || e.Actor != "" {
+			t.Errorf("unexpected event %+v", e)
+		}
+		if !strings.Contains(e.Reason, "version conflict") {
+			t.Errorf("expected a version conflict reason, got %q", e.Reason)
+		}
+	})
+}
+
+func TestAuditSecretRotation(t *testing.T) {
+	type TestConfig struct {
+		Value string            `json:"value"`
+		Token Sensitive[string] `json:"token"`
+	}
+
+	ctx := context.Background()
+	sink := NewMemoryAuditSink()
+	cfg := New[TestConfig](WithAuditSink[TestConfig](sink))
+
+	for _, token := range []string{"s3cr3t", "r0tated"} {
+		if err := cfg.Update(ctx, TestConfig{Value: "a", Token: NewSensitive(token)}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	events := sink.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	e := events[1]
+	want := []Change{{Path: "token", Old: RedactedValue, New: RedactedValue}}
+	if e.Outcome != AuditApplied || e.Version != 2 || !reflect.DeepEqual(e.Changes, want) {
+		t.Errorf("expected the rotation to be recorded as %+v, got %+v", want, e)
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(data), "s3cr3t") || strings.Contains(string(data), "r0tated") {
+		t.Errorf("audit events expose the secret: %s", data)
+	}
+}
+
+func TestAuditOrder(t *testing.T) {
+	type TestConfig struct {
+		Value int `json:"value"`
+	}
+
+	// A sink that yields mid-event lets a later update overtake an
+	// earlier one unless updates are serialized
+	sink := NewMemoryAuditSink()
+	slow := auditFunc(func(ctx context.Context, event AuditEvent) error {
+		time.Sleep(time.Millisecond)
+		return sink.Audit(ctx, event)
+	})
+	cfg := New[TestConfig](WithAuditSink[TestConfig](slow))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cfg.Update(context.Background(), TestConfig{Value: i}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	events := sink.Events()
+	if len(events) != 20 {
+		t.Fatalf("expected 20 events, got %d", len(events))
+	}
+	for i, e := range events {
+		if e.Version != uint64(i+1) {
+			t.Fatalf("event %d has version %d, expected events in version order", i, e.Version)
+		}
+	}
+}
+
+// auditFunc adapts a function to AuditSink
+type auditFunc func(ctx context.Context, event AuditEvent) error
+
+func (f auditFunc) Audit(ctx context.Context, event AuditEvent) error {
+	return f(ctx, event)
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileAuditSink(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := sink.Audit(context.Background(), AuditEvent{Source: SourceManual, Outcome: AuditApplied, Version: uint64(i + 1)}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	// Reopening appends rather than truncating
+	var versions []uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		versions = append(versions, event.Version)
+	}
+	if !reflect.DeepEqual(versions, []uint64{1, 2}) {
+		t.Errorf("expected versions [1 2], got %v", versions)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestWebhookAuditSink(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []AuditEvent
+		status   = http.StatusNoContent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var event AuditEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+	sink := NewWebhookAuditSink(server.URL, WithAuditHeader("Authorization", "Bearer token"))
+	cfg := New[TestConfig](WithAuditSink[TestConfig](sink))
+
+	if err := cfg.Update(WithActor(context.Background(), "deploy-bot"), TestConfig{Value: "a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	if len(received) != 1 || received[0].Actor != "deploy-bot" || received[0].Outcome != AuditApplied {
+		t.Errorf("unexpected events %+v", received)
+	}
+	status = http.StatusInternalServerError
+	mu.Unlock()
+
+	if err := sink.Audit(context.Background(), AuditEvent{}); err == nil {
+		t.Error("expected an error for a failing endpoint")
+	}
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		handler := newRecordHandler()
+		sink := NewWebhookAuditSink(server.URL + "/hooks/s3cr3t-token")
+		cfg := New[TestConfig](WithLogger[TestConfig](slog.New(handler)), WithAuditSink[TestConfig](sink))
+		if err := cfg.Update(context.Background(), TestConfig{Value: "a"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		rec := handler.find(t, "audit sink failed")
+		if msg := fmt.Sprint(rec["error"]); strings.Contains(msg, "s3cr3t-token") {
+			t.Errorf("logged error exposes the webhook URL: %s", msg)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package gorealconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec converts between raw source payloads and configuration values
+type Codec interface {
+	Unmarshal(data []byte, v any) error
+	Marshal(v any) ([]byte, error)
+}
+
+// JSONCodec is the default codec
+type JSONCodec struct{}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// YAMLCodec decodes YAML payloads. Struct fields are matched by their
+// yaml tags, or by their lowercased names without one.
+type YAMLCodec struct{}
+
+func (YAMLCodec) Unmarshal(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
+
+func (YAMLCodec) Marshal(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// TOMLCodec decodes TOML payloads. Struct fields are matched by their
+// toml tags, or by their names without one.
+type TOMLCodec struct{}
+
+func (TOMLCodec) Unmarshal(data []byte, v any) error {
+	return toml.Unmarshal(data, v)
+}
+
+func (TOMLCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RawSource is implemented by sources that can return their payloads
+// undecoded. Config prefers it over Source and decodes payloads with its
+// codec, see WithCodec. A nil payload means the source holds no
+// configuration yet.
+type RawSource interface {
+	// LoadRaw loads the initial payload
+	LoadRaw(ctx context.Context) ([]byte, error)
+
+	// WatchRaw watches for payload changes
+	WatchRaw(ctx context.Context) (<-chan []byte, error)
+}
+
+// WithCodec sets the codec used to decode payloads from sources that
+// implement RawSource. The default is JSONCodec.
+func WithCodec[T any](codec Codec) Option[T] {
+	return func(c *Config[T]) {
+		c.codec = codec
+	}
+}
+
+// prepare runs the value stages of the decode pipeline. Every value passes
+// through the same pipeline regardless of its source:
+//
+//	payload -> signature verification -> codec -> decryption ->
+//	secret resolution -> validation
+//
+// The value before prepare is what Config retains as raw, so ciphertext and
+// secret references never leave the process in plaintext.
+func (c *Config[T]) prepare(ctx context.Context, raw T) (T, error) {
+	value, err := c.decryptValues(raw)
+	if err != nil {
+		return value, fmt.Errorf("failed to decrypt config: %w", err)
+	}
+
+	value, err = c.resolveSecrets(ctx, value)
+	if err != nil {
+		return value, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	return value, nil
+}
+
+// decode verifies a raw payload when signatures are required and decodes
+// it with the configured codec
+func (c *Config[T]) decode(data []byte) (T, error) {
+	var zero T
+
+	data, err := c.verify(data)
+	if err != nil {
+		return zero, err
+	}
+
+	codec := c.codec
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+
+	var value T
+	if err := codec.Unmarshal(data, &value); err != nil {
+		var zero T
+		return zero, err
+	}
+	return value, nil
+}
+
+// decodeJSON decodes a raw payload for the typed Source methods of the
+// built-in sources. A nil payload decodes to the zero value.
+func decodeJSON[T any](data []byte) (T, error) {
+	var value T
+	if data == nil {
+		return value, nil
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		var zero T
+		return zero, err
+	}
+	return value, nil
+}
+
+// decodeWatch decodes raw payloads from a RawSource watch, dropping
+// payloads that fail to decode
+func decodeWatch[T any](ctx context.Context, raw <-chan []byte) <-chan T {
+	ch := make(chan T, 1)
+
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data, ok := <-raw:
+				if !ok {
+					return
+				}
+				if data == nil {
+					continue
+				}
+				value, err := decodeJSON[T](data)
+				if err != nil {
+					LoggerFromContext(ctx).Warn("payload rejected", "error", err, payloadAttr(data, err))
+					continue
+				}
+				select {
+				case ch <- value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
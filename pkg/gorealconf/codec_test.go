@@ -0,0 +1,51 @@
+package gorealconf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	type Database struct {
+		Host string `json:"host" yaml:"host" toml:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	type TestConfig struct {
+		Name     string   `json:"name" yaml:"name" toml:"name"`
+		Database Database `json:"database" yaml:"database" toml:"database"`
+	}
+	want := TestConfig{Name: "app", Database: Database{Host: "db", Port: 5432}}
+
+	tests := []struct {
+		name  string
+		codec Codec
+		input string
+	}{
+		{"json", JSONCodec{}, `{"name":"app","database":{"host":"db","port":5432}}`},
+		{"yaml", YAMLCodec{}, "name: app\ndatabase:\n  host: db\n  port: 5432\n"},
+		{"toml", TOMLCodec{}, "name = \"app\"\n\n[database]\nhost = \"db\"\nport = 5432\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TestConfig
+			if err := tt.codec.Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != want {
+				t.Errorf("expected %+v, got %+v", want, got)
+			}
+
+			data, err := tt.codec.Marshal(want)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var roundTrip TestConfig
+			if err := tt.codec.Unmarshal(data, &roundTrip); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(roundTrip, want) {
+				t.Errorf("round trip: expected %+v, got %+v", want, roundTrip)
+			}
+		})
+	}
+}
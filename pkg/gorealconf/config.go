@@ -3,20 +3,39 @@ package gorealconf
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type Config[T any] struct {
+	name           string
 	mu             sync.RWMutex
 	current        atomic.Pointer[T]
+	raw            *T
 	version        uint64
 	subscribers    map[chan T]struct{}
+	listeners      []*changeListener[T]
 	validator      func(old, new T) error
 	sources        []Source[T]
+	sourceNames    []string
 	enableRollback bool
-	metrics        *Metrics
+	metrics        MetricsRecorder
+	tracer         Tracer
+	logger         *slog.Logger
+	auditSinks     []AuditSink
+	auditMu        sync.Mutex // serializes audited updates, see WithAuditSink
+	collector      *MetricsCollector
+	history        []HistoryEntry[T]
+	historySize    int
+	resolvers      map[string]SecretResolver
+	secretRefresh  time.Duration
+	codec          Codec
+	keyring        *Keyring
+	trust          *TrustStore
+	rollout        atomic.Pointer[Rollout[T]]
+	freeze         *FreezeStrategy
 }
 
 type Option[T any] func(*Config[T])
@@ -25,76 +44,212 @@ func New[T any](opts ...Option[T]) *Config[T] {
 	cfg := &Config[T]{
 		subscribers: make(map[chan T]struct{}),
 		sources:     make([]Source[T], 0),
+		metrics:     noMetrics,
+		tracer:      noTracer{},
+		logger:      noLogger,
 	}
 
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
+	if cfg.collector != nil {
+		if cfg.name == "" {
+			cfg.name = "default"
+		}
+		cfg.metrics = cfg.collector.Metrics(cfg.name)
+	}
+	if cfg.name != "" {
+		cfg.logger = cfg.logger.With(LogKeyConfig, cfg.name)
+	}
+
 	return cfg
 }
 
 // Load initializes the configuration from all sources
 func (c *Config[T]) Load(ctx context.Context) error {
-	for _, source := range c.sources {
-		value, err := source.Load(ctx)
-		if err != nil {
-			if c.metrics != nil {
-				c.metrics.loadErrors.Inc()
-			}
-			return fmt.Errorf("failed to load config from source: %w", err)
-		}
-
-		// Apply validation if configured
-		if c.validator != nil {
-			oldValue := c.Get(ctx)
-			if err := c.validator(oldValue, value); err != nil {
-				if c.metrics != nil {
-					c.metrics.validationErrs.Inc()
-				}
-				if c.enableRollback {
-					if c.metrics != nil {
-						c.metrics.rollbackCount.Inc()
-					}
-					return fmt.Errorf("validation failed: %w", err)
-				}
-			}
-		}
+	release, err := c.registerMetrics(ctx)
+	if err != nil {
+		return err
+	}
 
-		if err := c.Update(ctx, value); err != nil {
-			return fmt.Errorf("failed to update config: %w", err)
+	for i, source := range c.sources {
+		if err := c.loadFrom(ctx, source, c.sourceNames[i]); err != nil {
+			release()
+			return err
 		}
 	}
 
 	// Start watching all sources
-	for _, source := range c.sources {
-		go c.watchSource(ctx, source)
+	for i, source := range c.sources {
+		go c.watchSource(ctx, source, c.sourceNames[i])
+	}
+
+	if c.secretRefresh > 0 && len(c.resolvers) > 0 {
+		go c.refreshSecrets(ctx)
 	}
 
 	return nil
 }
 
-func (c *Config[T]) watchSource(ctx context.Context, source Source[T]) {
-	changes, err := source.Watch(ctx)
+// loadFrom applies the initial value of the named source
+func (c *Config[T]) loadFrom(ctx context.Context, source Source[T], name string) (err error) {
+	ctx, end := c.startSpan(ctx, SpanLoad, name)
+	defer func() { end(err) }()
+
+	logger := c.log(name)
+	value, ok, err := c.loadSource(contextWithLogger(ctx, logger), source, name)
 	if err != nil {
-		if c.metrics != nil {
-			c.metrics.watchErrors.Inc()
+		c.metrics.IncLoadErrors(name)
+		logger.Error("source load failed", "error", err)
+		return fmt.Errorf("failed to load config from source %s: %w", name, err)
+	}
+	if !ok {
+		return nil
+	}
+
+	if err := c.update(ctx, value, nil, name, updateInitial); err != nil {
+		return fmt.Errorf("failed to update config from source %s: %w", name, err)
+	}
+	return nil
+}
+
+// loadSource loads the initial value of a source, decoding raw payloads
+// with the configured codec. It reports false when a raw source holds no
+// configuration yet.
+func (c *Config[T]) loadSource(ctx context.Context, source Source[T], name string) (T, bool, error) {
+	var zero T
+
+	raw, ok := source.(RawSource)
+	if !ok {
+		if c.trust != nil {
+			return zero, false, errUnverifiableSource
 		}
+		value, err := source.Load(ctx)
+		return value, err == nil, err
+	}
+
+	data, err := raw.LoadRaw(ctx)
+	if err != nil || data == nil {
+		return zero, false, err
+	}
+
+	value, err := c.decodeFrom(ctx, name, data)
+	if err != nil {
+		return zero, false, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return value, true, nil
+}
+
+// decodeFrom decodes a payload received from the named source
+func (c *Config[T]) decodeFrom(ctx context.Context, name string, data []byte) (T, error) {
+	_, end := c.startSpan(ctx, SpanDecode, name)
+	value, err := c.decode(data)
+	end(err)
+	if err != nil {
+		c.metrics.IncDecodeErrors(name)
+		c.log(name).Warn("payload rejected",
+			LogKeyVersion, c.Version(),
+			"error", err,
+			payloadAttr(data, err),
+		)
+	}
+	return value, err
+}
+
+// watchSource applies changes from source until ctx is done. When the
+// watch fails or its channel closes, it is restarted with backoff.
+func (c *Config[T]) watchSource(ctx context.Context, source Source[T], name string) {
+	if _, ok := source.(RawSource); !ok && c.trust != nil {
+		// Values from typed sources cannot be verified
 		return
 	}
 
+	logger := c.log(name)
+	ctx = contextWithLogger(ctx, logger)
+
+	backoff := time.Second
+	for {
+		received, err := c.consumeSource(ctx, source, name)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			backoff = time.Second
+		}
+		if err != nil {
+			c.metrics.IncWatchErrors(name)
+			logger.Error("source watch failed", "error", err, "retry", backoff)
+		} else {
+			logger.Warn("source disconnected", "retry", backoff)
+		}
+		if !sleepContext(ctx, backoff) {
+			return
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+		c.metrics.IncWatchReconnects(name)
+	}
+}
+
+// consumeSource runs a single watch of source until its channel closes. It
+// reports whether any change was received.
+func (c *Config[T]) consumeSource(ctx context.Context, source Source[T], name string) (bool, error) {
+	if raw, ok := source.(RawSource); ok {
+		return c.consumeRawSource(ctx, raw, name)
+	}
+
+	changes, err := source.Watch(ctx)
+	if err != nil {
+		return false, err
+	}
+	c.log(name).Info("source connected", LogKeyVersion, c.Version())
+
+	received := false
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return received, nil
 		case value, ok := <-changes:
 			if !ok {
-				return
+				return received, nil
 			}
-			if err := c.Update(ctx, value); err != nil {
-				if c.metrics != nil {
-					c.metrics.updateErrors.Inc()
-				}
+			received = true
+			if err := c.update(ctx, value, nil, name, 0); err != nil {
+				c.metrics.IncUpdateErrors(name)
+			}
+		}
+	}
+}
+
+func (c *Config[T]) consumeRawSource(ctx context.Context, source RawSource, name string) (bool, error) {
+	changes, err := source.WatchRaw(ctx)
+	if err != nil {
+		return false, err
+	}
+	c.log(name).Info("source connected", LogKeyVersion, c.Version())
+
+	received := false
+	for {
+		select {
+		case <-ctx.Done():
+			return received, nil
+		case data, ok := <-changes:
+			if !ok {
+				return received, nil
+			}
+			if data == nil {
+				continue
+			}
+			received = true
+			value, err := c.decodeFrom(ctx, name, data)
+			if err != nil {
+				c.metrics.IncUpdateErrors(name)
+				continue
+			}
+			if err := c.update(ctx, value, nil, name, 0); err != nil {
+				c.metrics.IncUpdateErrors(name)
 			}
 		}
 	}
@@ -112,18 +267,37 @@ func WithRollback[T any](enable bool) Option[T] {
 	}
 }
 
-func WithMetrics[T any](metrics *Metrics) Option[T] {
+// WithMetrics records the config's metrics, for example with Metrics from
+// NewMetrics
+func WithMetrics[T any](metrics MetricsRecorder) Option[T] {
 	return func(c *Config[T]) {
+		if metrics == nil {
+			metrics = noMetrics
+		}
 		c.metrics = metrics
 	}
 }
 
+// WithHistory keeps the last size applied values, see Config.History
+func WithHistory[T any](size int) Option[T] {
+	return func(c *Config[T]) {
+		c.historySize = size
+	}
+}
+
 func WithSource[T any](source Source[T]) Option[T] {
 	return func(c *Config[T]) {
 		c.AddSource(source)
 	}
 }
 
+// WithNamedSource adds source under name, which labels its metrics
+func WithNamedSource[T any](name string, source Source[T]) Option[T] {
+	return func(c *Config[T]) {
+		c.AddNamedSource(name, source)
+	}
+}
+
 func (c *Config[T]) Get(ctx context.Context) T {
 	value := c.current.Load()
 	if value == nil {
@@ -133,62 +307,224 @@ func (c *Config[T]) Get(ctx context.Context) T {
 	return *value
 }
 
+// GetFor returns the value to serve for the subject in ctx. While a
+// rollout started on this config is in progress, that is the candidate for
+// subjects in the current stage and the stable value for everyone else.
+func (c *Config[T]) GetFor(ctx context.Context) T {
+	if r := c.rollout.Load(); r != nil {
+		return r.Resolve(ctx)
+	}
+	return c.Get(ctx)
+}
+
+// Version returns the number of updates applied so far
+func (c *Config[T]) Version() uint64 {
+	return atomic.LoadUint64(&c.version)
+}
+
+// snapshot returns the current value as it was received, before secret
+// resolution, together with its version. It is what may leave the process.
+func (c *Config[T]) snapshot() (T, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var value T
+	if c.raw != nil {
+		value = *c.raw
+	}
+	return value, atomic.LoadUint64(&c.version)
+}
+
 func (c *Config[T]) Update(ctx context.Context, newValue T) error {
+	return c.update(ctx, newValue, nil, SourceManual, 0)
+}
+
+// UpdateIfVersion applies newValue only when the current version equals
+// version. It returns a *VersionConflictError otherwise.
+func (c *Config[T]) UpdateIfVersion(ctx context.Context, version uint64, newValue T) error {
+	return c.update(ctx, newValue, &version, SourceManual, 0)
+}
+
+// updateFlags change how update treats a value
+type updateFlags uint8
+
+const (
+	// updateStrict rejects values that fail validation even without
+	// WithRollback, for callers that report the failure to a client
+	updateStrict updateFlags = 1 << iota
+
+	// updateInitial marks values applied by Load, which freezes allow
+	updateInitial
+)
+
+// update applies rawValue received from the named source
+func (c *Config[T]) update(ctx context.Context, rawValue T, expected *uint64, source string, flags updateFlags) (err error) {
+	ctx, end := c.startSpan(ctx, SpanApply, source)
+	defer func() { end(err) }()
+
+	// Audited updates run one at a time, so sinks receive events in
+	// version order. Unlocked after the deferred audit below.
+	if len(c.auditSinks) > 0 {
+		c.auditMu.Lock()
+		defer c.auditMu.Unlock()
+	}
+
 	start := time.Now()
 	oldValue := c.Get(ctx)
+	logger := c.log(source)
+
+	previous, version := c.snapshot()
+	audit := AuditEvent{Source: source, Outcome: AuditRejected}
+	defer func() {
+		if err != nil {
+			audit.Reason = err.Error()
+		}
+		audit.Version = version
+		c.audit(ctx, audit, previous, rawValue)
+	}()
 
+	if flags&updateInitial == 0 && c.frozen() {
+		logger.Warn("update rejected by freeze", LogKeyVersion, c.Version())
+		return ErrConfigFrozen
+	}
+
+	newValue, err := c.prepare(ctx, rawValue)
+	if err != nil {
+		logger.Error("update failed", LogKeyVersion, c.Version(), "error", err)
+		return err
+	}
+
+	valid := "true"
 	if c.validator != nil {
-		if err := c.validator(oldValue, newValue); err != nil {
-			if c.metrics != nil {
-				c.metrics.validationErrs.Inc()
-			}
-			if c.enableRollback {
-				if c.metrics != nil {
-					c.metrics.rollbackCount.Inc()
+		_, endValidate := c.startSpan(ctx, SpanValidate, source)
+		verr := c.validator(oldValue, newValue)
+		endValidate(verr)
+		audit.Validation = validationOutcome(verr)
+
+		if verr != nil {
+			valid = "false"
+			c.metrics.IncValidationErrors(source)
+			logger.Warn("validation failed",
+				LogKeyVersion, c.Version(),
+				"rules", ruleNames(verr),
+				"error", verr,
+				"rejected", c.enableRollback || flags&updateStrict != 0,
+			)
+			if c.enableRollback || flags&updateStrict != 0 {
+				if c.enableRollback {
+					audit.Outcome = AuditRolledBack
+					c.metrics.IncRollbackCount()
+					logger.Warn("update rolled back", LogKeyVersion, c.Version())
+				}
+				return &ValidationError{
+					Message: verr.Error(),
+					Old:     Redacted(oldValue),
+					New:     Redacted(newValue),
+					Cause:   verr,
 				}
-				return err
 			}
 		}
 	}
 
 	c.mu.Lock()
+	if expected != nil && *expected != atomic.LoadUint64(&c.version) {
+		actual := atomic.LoadUint64(&c.version)
+		if c.raw != nil {
+			previous = *c.raw
+		}
+		version = actual
+		c.mu.Unlock()
+		logger.Debug("update lost version race", LogKeyVersion, actual, "expected", *expected)
+		return &VersionConflictError{Expected: *expected, Actual: actual}
+	}
+
+	if c.raw != nil {
+		previous = *c.raw
+	}
 	c.current.Store(&newValue)
+	c.raw = &rawValue
 	newVersion := atomic.AddUint64(&c.version, 1)
+	c.recordHistory(newVersion, rawValue)
+	audit.Outcome = AuditApplied
+	version = newVersion
 
-	if c.metrics != nil {
-		c.metrics.configUpdates.WithLabelValues("manual", "true").Inc()
-		c.metrics.configVersions.Set(float64(newVersion))
-		c.metrics.updateDuration.Observe(time.Since(start).Seconds())
-	}
+	applied := time.Now()
+	c.metrics.IncConfigUpdates(source, valid)
+	c.metrics.SetConfigVersion(float64(newVersion))
+	c.metrics.ObserveUpdateDuration(applied.Sub(start).Seconds())
+	c.metrics.SetLastUpdate(source, applied)
 
 	subscribers := make([]chan T, 0, len(c.subscribers))
 	for sub := range c.subscribers {
 		subscribers = append(subscribers, sub)
 	}
+	listeners := c.listeners
 	c.mu.Unlock()
 
-	go func() {
-		for _, sub := range subscribers {
-			select {
-			case sub <- newValue:
-			default:
-				c.mu.Lock()
-				delete(c.subscribers, sub)
-				c.mu.Unlock()
-				close(sub)
-			}
-		}
-	}()
+	logger.Info("config updated", LogKeyVersion, newVersion)
+
+	event := ChangeEvent[T]{
+		Value:    newValue,
+		Version:  newVersion,
+		Source:   source,
+		raw:      rawValue,
+		previous: previous,
+	}
+	go c.notify(ctx, subscribers, listeners, event, applied)
 
 	return nil
 }
 
+// notify delivers an applied update to subscribers and change listeners.
+// Its span is a child of the update's, so delays show up in the trace.
+func (c *Config[T]) notify(ctx context.Context, subscribers []chan T, listeners []*changeListener[T], event ChangeEvent[T], applied time.Time) {
+	ctx, end := c.tracer.Start(context.WithoutCancel(ctx), SpanNotify, SpanAttributes{
+		Config:  c.name,
+		Source:  event.Source,
+		Version: event.Version,
+	})
+	defer end(nil)
+
+	dropped := 0
+	c.mu.Lock()
+	for _, sub := range subscribers {
+		// Skip subscribers that unsubscribed since the snapshot
+		if _, ok := c.subscribers[sub]; !ok {
+			continue
+		}
+		select {
+		case sub <- event.Value:
+		default:
+			delete(c.subscribers, sub)
+			close(sub)
+			dropped++
+		}
+	}
+	c.metrics.ObserveSubscriberLag(time.Since(applied).Seconds())
+	c.metrics.SetSubscribers(len(c.subscribers))
+	remaining := len(c.subscribers)
+	c.mu.Unlock()
+
+	if dropped > 0 {
+		c.log(event.Source).Warn("slow subscribers dropped",
+			LogKeyVersion, event.Version,
+			"dropped", dropped,
+			"remaining", remaining,
+		)
+	}
+
+	for _, listener := range listeners {
+		listener.fn(ctx, event)
+	}
+}
+
 func (c *Config[T]) Subscribe(ctx context.Context) (<-chan T, func()) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	ch := make(chan T, 1)
 	c.subscribers[ch] = struct{}{}
+	c.metrics.SetSubscribers(len(c.subscribers))
 
 	if current := c.current.Load(); current != nil {
 		select {
@@ -200,8 +536,11 @@ func (c *Config[T]) Subscribe(ctx context.Context) (<-chan T, func()) {
 	return ch, func() {
 		c.mu.Lock()
 		defer c.mu.Unlock()
-		delete(c.subscribers, ch)
-		close(ch)
+		if _, ok := c.subscribers[ch]; ok {
+			delete(c.subscribers, ch)
+			close(ch)
+			c.metrics.SetSubscribers(len(c.subscribers))
+		}
 	}
 }
 
@@ -233,8 +572,20 @@ func (c *Config[T]) Watch(ctx context.Context) (<-chan T, error) {
 	return watchCh, nil
 }
 
+// AddSource adds source under its own name when it is a NamedSource, or
+// "source-<n>" otherwise
 func (c *Config[T]) AddSource(source Source[T]) {
+	name := fmt.Sprintf("source-%d", len(c.sources))
+	if named, ok := source.(NamedSource); ok {
+		name = named.Name()
+	}
+	c.AddNamedSource(name, source)
+}
+
+// AddNamedSource adds source under name, which labels its metrics
+func (c *Config[T]) AddNamedSource(name string, source Source[T]) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.sources = append(c.sources, source)
+	c.sourceNames = append(c.sourceNames, name)
 }
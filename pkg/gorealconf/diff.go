@@ -0,0 +1,326 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is a field that differs between two configuration values. Path
+// uses the JSON field names, such as "database.hosts[0]"; Old is nil for
+// added fields and New is nil for removed ones.
+type Change struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// DefaultSecretKeys are the field names whose values Diff masks. A field
+// matches when its name, lowercased and without underscores or dashes,
+// contains one of them.
+var DefaultSecretKeys = []string{"password", "passwd", "secret", "token", "apikey", "privatekey", "credential"}
+
+type diffOptions struct {
+	secretKeys []string
+}
+
+// DiffOption configures Diff and DiffSources
+type DiffOption func(*diffOptions)
+
+// WithSecretKeys replaces DefaultSecretKeys, for example to mask
+// additional fields of untyped documents. Call it with no names to mask
+// tagged fields only.
+func WithSecretKeys(names ...string) DiffOption {
+	return func(o *diffOptions) {
+		o.secretKeys = make([]string, len(names))
+		for i, name := range names {
+			o.secretKeys[i] = normalizeKey(name)
+		}
+	}
+}
+
+// Diff returns the fields that differ between old and new, ordered by
+// path. Values are compared as they are, but reported through Redacted,
+// and fields named like secrets are masked as well, so a changed secret
+// is reported with RedactedValue on both sides even for untyped values
+// such as map[string]any.
+func Diff[T any](old, new T, opts ...DiffOption) ([]Change, error) {
+	return diffValues(old, new, old, new, opts)
+}
+
+// diffValues compares old and new and reports them through oldShown and
+// newShown, which have the same shape but may hide more of the values
+func diffValues[T any](old, new, oldShown, newShown T, opts []DiffOption) ([]Change, error) {
+	options := diffOptions{secretKeys: DefaultSecretKeys}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var docs [4]any
+	for i, value := range []T{old, new, Redacted(oldShown), Redacted(newShown)} {
+		doc, err := toDocument(value)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = doc
+	}
+
+	var changes []Change
+	diffDocuments("", docs[0], docs[1], maskKeys(docs[2], options.secretKeys), maskKeys(docs[3], options.secretKeys), &changes)
+	return changes, nil
+}
+
+// DiffSources loads a value from each source and compares them, for
+// example a staging store against production. Payloads are decoded as
+// JSON; use Config.DiffSources for other codecs, signed payloads or
+// encrypted values.
+func DiffSources[T any](ctx context.Context, from, to Source[T], opts ...DiffOption) (*DiffReport, error) {
+	return New[T]().DiffSources(ctx, from, to, opts...)
+}
+
+// DiffSources loads a value from each source the way c loads its own
+// sources, verified, decoded with its codec and decrypted, and compares
+// them. Encrypted values are compared decrypted but always reported as
+// RedactedValue. Secret references are compared unresolved. The report is
+// labelled with the names of NamedSource sources, or "from" and "to".
+func (c *Config[T]) DiffSources(ctx context.Context, from, to Source[T], opts ...DiffOption) (*DiffReport, error) {
+	report := &DiffReport{From: "from", To: "to"}
+	if named, ok := from.(NamedSource); ok {
+		report.From = named.Name()
+	}
+	if named, ok := to.(NamedSource); ok {
+		report.To = named.Name()
+	}
+
+	old, oldShown, err := c.loadForDiff(ctx, from, report.From)
+	if err != nil {
+		return nil, err
+	}
+	next, nextShown, err := c.loadForDiff(ctx, to, report.To)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Changes, err = diffValues(old, next, oldShown, nextShown, opts)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// loadForDiff loads and decrypts the value of the named source. shown is
+// the value with every encrypted string replaced by RedactedValue, so
+// encrypted fields are compared as plaintext but never reported as such.
+// A source holding no configuration compares as the zero value.
+func (c *Config[T]) loadForDiff(ctx context.Context, source Source[T], name string) (value, shown T, err error) {
+	value, _, err = c.loadSource(contextWithLogger(ctx, c.log(name)), source, name)
+	if err != nil {
+		return value, shown, fmt.Errorf("failed to load %s: %w", name, err)
+	}
+	shown, err = rewriteStrings(value, func(_ string, _ bool, s string) (string, error) {
+		if encryptedPattern.MatchString(s) {
+			return RedactedValue, nil
+		}
+		return s, nil
+	})
+	if err != nil {
+		return value, shown, fmt.Errorf("failed to mask %s: %w", name, err)
+	}
+	value, err = c.decryptValues(value)
+	if err != nil {
+		return value, shown, fmt.Errorf("failed to decrypt %s: %w", name, err)
+	}
+	return value, shown, nil
+}
+
+// DiffReport is the comparison of two configurations
+type DiffReport struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Changes []Change `json:"changes"`
+}
+
+// Identical reports whether the configurations are the same
+func (r *DiffReport) Identical() bool {
+	return len(r.Changes) == 0
+}
+
+// WriteText writes the report as a unified diff with one hunk per field,
+// values encoded as JSON. Nothing is written for identical configurations.
+//
+//	--- staging
+//	+++ production
+//	@@ database.port @@
+//	-5432
+//	+5433
+func (r *DiffReport) WriteText(w io.Writer) error {
+	if r.Identical() {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", r.From, r.To)
+	for _, c := range r.Changes {
+		fmt.Fprintf(&b, "@@ %s @@\n", c.Path)
+		if c.Old != nil {
+			fmt.Fprintf(&b, "-%s\n", diffValue(c.Old))
+		}
+		if c.New != nil {
+			fmt.Fprintf(&b, "+%s\n", diffValue(c.New))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as an indented JSON object
+func (r *DiffReport) WriteJSON(w io.Writer) error {
+	report := *r
+	if report.Changes == nil {
+		report.Changes = []Change{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func diffValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// toDocument converts value to its generic JSON form
+func toDocument(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// maskKeys replaces the values of object fields matching secretKeys with
+// RedactedValue, in place
+func maskKeys(doc any, secretKeys []string) any {
+	switch d := doc.(type) {
+	case map[string]any:
+		for k, v := range d {
+			if isSecretKey(k, secretKeys) {
+				d[k] = RedactedValue
+			} else {
+				d[k] = maskKeys(v, secretKeys)
+			}
+		}
+	case []any:
+		for i, v := range d {
+			d[i] = maskKeys(v, secretKeys)
+		}
+	}
+	return doc
+}
+
+func isSecretKey(key string, secretKeys []string) bool {
+	key = normalizeKey(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// absentValue marks a position missing from a masked document, such as a
+// field of a secret struct that Redacted zeroed
+type absentValue struct{}
+
+// diffDocuments appends the differences between two generic JSON values
+// at path. Objects and arrays are compared element by element; anything
+// else is reported as a whole. oldMasked and newMasked are the masked
+// forms of the values, and a change is reported masked when either value
+// differs from its masked form.
+func diffDocuments(path string, old, new, oldMasked, newMasked any, changes *[]Change) {
+	switch o := old.(type) {
+	case map[string]any:
+		if n, ok := new.(map[string]any); ok {
+			keys := make([]string, 0, len(o)+len(n))
+			for k := range o {
+				keys = append(keys, k)
+			}
+			for k := range n {
+				if _, ok := o[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				diffDocuments(joinPath(path, k), o[k], n[k], maskedField(oldMasked, k), maskedField(newMasked, k), changes)
+			}
+			return
+		}
+
+	case []any:
+		if n, ok := new.([]any); ok {
+			for i := 0; i < len(o) || i < len(n); i++ {
+				var oe, ne any
+				if i < len(o) {
+					oe = o[i]
+				}
+				if i < len(n) {
+					ne = n[i]
+				}
+				diffDocuments(fmt.Sprintf("%s[%d]", path, i), oe, ne, maskedElement(oldMasked, i), maskedElement(newMasked, i), changes)
+			}
+			return
+		}
+	}
+
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+	if wasMasked(old, oldMasked) || wasMasked(new, newMasked) {
+		old, new = maskChange(old), maskChange(new)
+	}
+	*changes = append(*changes, Change{Path: path, Old: old, New: new})
+}
+
+func maskedField(masked any, key string) any {
+	if m, ok := masked.(map[string]any); ok {
+		if v, ok := m[key]; ok {
+			return v
+		}
+	}
+	return absentValue{}
+}
+
+func maskedElement(masked any, i int) any {
+	if a, ok := masked.([]any); ok && i < len(a) {
+		return a[i]
+	}
+	return absentValue{}
+}
+
+// wasMasked reports whether masking changed a present value
+func wasMasked(value, masked any) bool {
+	return value != nil && !reflect.DeepEqual(value, masked)
+}
+
+func maskChange(value any) any {
+	if value == nil {
+		return nil
+	}
+	return RedactedValue
+}
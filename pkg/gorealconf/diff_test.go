@@ -0,0 +1,214 @@
+package gorealconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	t.Run("secret keys", func(t *testing.T) {
+		old := map[string]any{"db": map[string]any{"host": "a", "Password": "one", "api-token": "x"}}
+		next := map[string]any{"db": map[string]any{"host": "b", "Password": "two", "api-token": "y"}}
+
+		tests := []struct {
+			name string
+			opts []DiffOption
+			want []Change
+		}{
+			{"defaults", nil, []Change{
+				{Path: "db.Password", Old: RedactedValue, New: RedactedValue},
+				{Path: "db.api-token", Old: RedactedValue, New: RedactedValue},
+				{Path: "db.host", Old: "a", New: "b"},
+			}},
+			{"custom", []DiffOption{WithSecretKeys("HOST")}, []Change{
+				{Path: "db.Password", Old: "one", New: "two"},
+				{Path: "db.api-token", Old: "x", New: "y"},
+				{Path: "db.host", Old: RedactedValue, New: RedactedValue},
+			}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := Diff(old, next, tt.opts...)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("expected %+v, got %+v", tt.want, got)
+				}
+			})
+		}
+	})
+
+	t.Run("changed secrets", func(t *testing.T) {
+		type TestConfig struct {
+			Token  Sensitive[string] `json:"token"`
+			Keys   []string          `json:"keys" secret:"true"`
+			Stable Sensitive[string] `json:"stable"`
+		}
+		old := TestConfig{Token: NewSensitive("a"), Keys: []string{"k1"}, Stable: NewSensitive("s")}
+		next := TestConfig{Token: NewSensitive("b"), Keys: []string{"k1", "k2"}, Stable: NewSensitive("s")}
+
+		got, err := Diff(old, next)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []Change{
+			{Path: "keys[1]", New: RedactedValue},
+			{Path: "token", Old: RedactedValue, New: RedactedValue},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		type TestConfig struct {
+			Port     int    `json:"port"`
+			Debug    bool   `json:"debug,omitempty"`
+			Password string `json:"password" secret:"true"`
+		}
+
+		dir := t.TempDir()
+		source := func(name, content string) *FileSource[TestConfig] {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			s, err := NewFileSource[TestConfig](path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return s
+		}
+		staging := source("staging.json", `{"port":8080,"debug":true,"password":"one"}`)
+		production := source("production.json", `{"port":80,"password":"two"}`)
+
+		report, err := DiffSources[TestConfig](context.Background(), staging, production)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if report.Identical() {
+			t.Fatal("expected differences")
+		}
+
+		var text bytes.Buffer
+		if err := report.WriteText(&text); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "--- " + staging.Name() + "\n+++ " + production.Name() + "\n" +
+			"@@ debug @@\n-true\n" +
+			"@@ password @@\n-\"[REDACTED]\"\n+\"[REDACTED]\"\n" +
+			"@@ port @@\n-8080\n+80\n"
+		if text.String() != want {
+			t.Errorf("expected %q, got %q", want, text.String())
+		}
+
+		var data bytes.Buffer
+		if err := report.WriteJSON(&data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var decoded DiffReport
+		if err := json.Unmarshal(data.Bytes(), &decoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(decoded.Changes) != 3 || decoded.From != report.From {
+			t.Errorf("unexpected JSON report %s", data.String())
+		}
+		if output := text.String() + data.String(); strings.Contains(output, `"one"`) || strings.Contains(output, `"two"`) {
+			t.Error("expected secrets to be redacted")
+		}
+
+		report, err = DiffSources[TestConfig](context.Background(), staging, staging)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data.Reset()
+		if err := report.WriteJSON(&data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !report.Identical() || !strings.Contains(data.String(), `"changes": []`) {
+			t.Errorf("expected an identical report, got %s", data.String())
+		}
+	})
+
+	t.Run("config sources", func(t *testing.T) {
+		type TestConfig struct {
+			Port     int    `json:"port" yaml:"port"`
+			Password string `json:"password" yaml:"password" secret:"true"`
+			DSN      string `json:"dsn" yaml:"dsn"`
+		}
+
+		keyring := NewKeyring()
+		if _, err := keyring.Rotate(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dir := t.TempDir()
+		source := func(name string, port int, password string) *FileSource[TestConfig] {
+			encrypted, err := keyring.Encrypt(password)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dsn, err := keyring.Encrypt("postgres://app:" + password + "@db")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			path := filepath.Join(dir, name)
+			content := fmt.Sprintf("port: %d\npassword: %s\ndsn: %s\n", port, encrypted, dsn)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			s, err := NewFileSource[TestConfig](path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return s
+		}
+
+		cfg := New[TestConfig](
+			WithCodec[TestConfig](YAMLCodec{}),
+			WithDecryption[TestConfig](keyring),
+		)
+		ctx := context.Background()
+
+		// Each file holds its own ciphertext of the same password
+		report, err := cfg.DiffSources(ctx, source("a.yaml", 80, "hunter2"), source("b.yaml", 8080, "hunter2"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []Change{{Path: "port", Old: float64(80), New: float64(8080)}}
+		if !reflect.DeepEqual(report.Changes, want) {
+			t.Errorf("expected %+v, got %+v", want, report.Changes)
+		}
+
+		report, err = cfg.DiffSources(ctx, source("c.yaml", 80, "hunter2"), source("d.yaml", 80, "rotated"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// The DSN is not a secret field, but it was encrypted
+		want = []Change{
+			{Path: "dsn", Old: RedactedValue, New: RedactedValue},
+			{Path: "password", Old: RedactedValue, New: RedactedValue},
+		}
+		if !reflect.DeepEqual(report.Changes, want) {
+			t.Errorf("expected %+v, got %+v", want, report.Changes)
+		}
+		var text strings.Builder
+		if err := report.WriteText(&text); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(text.String(), "hunter2") || strings.Contains(text.String(), "rotated") {
+			t.Errorf("expected no plaintext in the report, got:\n%s", text.String())
+		}
+
+		if _, err := DiffSources[TestConfig](ctx, source("e.yaml", 80, "x"), source("f.yaml", 80, "x")); err == nil {
+			t.Error("expected YAML to fail the default JSON codec")
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package gorealconf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// encryptedPattern matches values of the form ENC[aes256gcm:<key id>:<data>]
+var encryptedPattern = regexp.MustCompile(`ENC\[([a-z0-9]+):([A-Za-z0-9_.-]+):([A-Za-z0-9+/=]+)\]`)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+const encryptionScheme = "aes256gcm"
+
+// Keyring holds named AES-256 keys. The primary key encrypts new values
+// while every key can decrypt, so values encrypted before a rotation stay
+// readable until they are re-encrypted.
+type Keyring struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string][]byte
+}
+
+type keyringFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[string][]byte),
+	}
+}
+
+// LoadKeyring reads a keyring file written by Keyring.Save
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid keyring %s: %w", path, err)
+	}
+
+	k := NewKeyring()
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s in keyring %s: %w", id, path, err)
+		}
+		if err := k.AddKey(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := k.keys[file.Primary]; !ok {
+		return nil, fmt.Errorf("primary key %q not found in keyring %s", file.Primary, path)
+	}
+	k.primary = file.Primary
+
+	return k, nil
+}
+
+// Save writes the keyring to path, readable by the owner only
+func (k *Keyring) Save(path string) error {
+	k.mu.RLock()
+	file := keyringFile{
+		Primary: k.primary,
+		Keys:    make(map[string]string, len(k.keys)),
+	}
+	for id, key := range k.keys {
+		file.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	}
+	k.mu.RUnlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// AddKey adds a 32 byte key. The first key added becomes the primary key.
+func (k *Keyring) AddKey(id string, key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("key %s must be 32 bytes, got %d", id, len(key))
+	}
+	if !keyIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = append([]byte(nil), key...)
+	if k.primary == "" {
+		k.primary = id
+	}
+	return nil
+}
+
+// Rotate generates a new key and makes it the primary key. It returns the
+// new key id.
+func (k *Keyring) Rotate() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	id := "k" + hex.EncodeToString(suffix)
+
+	if err := k.AddKey(id, key); err != nil {
+		return "", err
+	}
+
+	k.mu.Lock()
+	k.primary = id
+	k.mu.Unlock()
+	return id, nil
+}
+
+// Primary returns the id of the key used for encryption
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Encrypt encrypts plaintext with the primary key, returning a value of the
+// form ENC[aes256gcm:<key id>:<data>] that can be committed to a file or
+// stored in a backend
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	k.mu.RLock()
+	id, key := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+
+	if key == nil {
+		return "", errors.New("keyring has no primary key")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+
+	return fmt.Sprintf("ENC[%s:%s:%s]", encryptionScheme, id, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt replaces every ENC[...] value in s with its plaintext
+func (k *Keyring) Decrypt(s string) (string, error) {
+	var decryptErr error
+	out := encryptedPattern.ReplaceAllStringFunc(s, func(token string) string {
+		if decryptErr != nil {
+			return token
+		}
+		plaintext, err := k.decryptToken(encryptedPattern.FindStringSubmatch(token))
+		if err != nil {
+			decryptErr = err
+			return token
+		}
+		return plaintext
+	})
+	return out, decryptErr
+}
+
+// Reencrypt decrypts every ENC[...] value in s and encrypts it again with
+// the primary key. Use it to migrate values after Rotate.
+func (k *Keyring) Reencrypt(s string) (string, error) {
+	var reencryptErr error
+	out := encryptedPattern.ReplaceAllStringFunc(s, func(token string) string {
+		if reencryptErr != nil {
+			return token
+		}
+		plaintext, err := k.decryptToken(encryptedPattern.FindStringSubmatch(token))
+		if err == nil {
+			token, err = k.Encrypt(plaintext)
+		}
+		reencryptErr = err
+		return token
+	})
+	return out, reencryptErr
+}
+
+func (k *Keyring) decryptToken(match []string) (string, error) {
+	scheme, id, encoded := match[1], match[2], match[3]
+	if scheme != encryptionScheme {
+		return "", fmt.Errorf("unsupported encryption scheme %q", scheme)
+	}
+
+	k.mu.RLock()
+	key := k.keys[id]
+	k.mu.RUnlock()
+	if key == nil {
+		return "", fmt.Errorf("unknown encryption key %q", id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value for key %q: %w", id, err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value for key %q", id)
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value with key %q: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecrets returns a copy of value with every string field tagged
+// `secret:"true"` and every string held by a Sensitive encrypted with the
+// keyring's primary key. Fields that are already encrypted are left as
+// they are.
+func EncryptSecrets[T any](keyring *Keyring, value T) (T, error) {
+	return rewriteStrings(value, func(path string, secret bool, s string) (string, error) {
+		if !secret || s == "" || strings.HasPrefix(s, "ENC[") {
+			return s, nil
+		}
+		encrypted, err := keyring.Encrypt(s)
+		if err != nil {
+			return s, fmt.Errorf("failed to encrypt %s: %w", path, err)
+		}
+		return encrypted, nil
+	})
+}
+
+// WithDecryption decrypts ENC[...] values with keys from keyring after
+// decoding and before secret resolution and validation
+func WithDecryption[T any](keyring *Keyring) Option[T] {
+	return func(c *Config[T]) {
+		c.keyring = keyring
+	}
+}
+
+// decryptValues returns a copy of value with every ENC[...] value decrypted
+func (c *Config[T]) decryptValues(value T) (T, error) {
+	if c.keyring == nil {
+		return value, nil
+	}
+
+	return rewriteStrings(value, func(path string, _ bool, s string) (string, error) {
+		if !strings.Contains(s, "ENC[") {
+			return s, nil
+		}
+		plaintext, err := c.keyring.Decrypt(s)
+		if err != nil {
+			return s, fmt.Errorf("%s: %w", path, err)
+		}
+		return plaintext, nil
+	})
+}
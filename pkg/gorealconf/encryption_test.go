@@ -0,0 +1,119 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	type TestConfig struct {
+		User     string            `json:"user"`
+		Password string            `json:"password" secret:"true"`
+		Token    Sensitive[string] `json:"token"`
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	keyring := NewKeyring()
+	oldKey, err := keyring.Rotate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	encrypted, err := EncryptSecrets(keyring, TestConfig{User: "app", Password: "hunter2", Token: NewSensitive("s3cr3t")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if encrypted.User != "app" || !strings.HasPrefix(encrypted.Password, "ENC[aes256gcm:"+oldKey+":") {
+		t.Fatalf("unexpected encrypted config %+v", encrypted)
+	}
+	if !strings.HasPrefix(encrypted.Token.Value(), "ENC[aes256gcm:"+oldKey+":") {
+		t.Fatalf("expected the Sensitive token to be encrypted, got %q", encrypted.Token.Value())
+	}
+
+	// Rotating keeps values encrypted with the previous key readable
+	newKey, err := keyring.Rotate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyringPath := filepath.Join(dir, "keyring.json")
+	if err := keyring.Save(keyringPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := LoadKeyring(keyringPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.Primary() != newKey {
+		t.Errorf("expected primary %s, got %s", newKey, loaded.Primary())
+	}
+
+	t.Run("file source", func(t *testing.T) {
+		data, _ := json.Marshal(encrypted)
+		configPath := filepath.Join(dir, "config.json")
+		if err := os.WriteFile(configPath, data, 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		source, err := NewFileSource[TestConfig](configPath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		cfg := New[TestConfig](
+			WithSource[TestConfig](source),
+			WithDecryption[TestConfig](loaded),
+			WithHistory[TestConfig](1),
+		)
+		if err := cfg.Load(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := cfg.Get(ctx).Password; got != "hunter2" {
+			t.Errorf("expected decrypted password, got %q", got)
+		}
+		if got := cfg.Get(ctx).Token.Value(); got != "s3cr3t" {
+			t.Errorf("expected decrypted token, got %q", got)
+		}
+		if got := cfg.History()[0].Value.Password; got != encrypted.Password {
+			t.Errorf("expected history to retain ciphertext, got %q", got)
+		}
+	})
+
+	t.Run("reencrypt", func(t *testing.T) {
+		reencrypted, err := loaded.Reencrypt(encrypted.Password)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(reencrypted, "ENC[aes256gcm:"+newKey+":") {
+			t.Errorf("expected value encrypted with %s, got %q", newKey, reencrypted)
+		}
+		if plaintext, err := loaded.Decrypt(reencrypted); err != nil || plaintext != "hunter2" {
+			t.Errorf("expected hunter2, got %q (%v)", plaintext, err)
+		}
+	})
+
+	t.Run("tampered value", func(t *testing.T) {
+		cfg := New[TestConfig](WithDecryption[TestConfig](keyring))
+
+		tampered := encrypted
+		tampered.Password = strings.Replace(encrypted.Password, oldKey, newKey, 1)
+		if err := cfg.Update(ctx, tampered); err == nil {
+			t.Error("expected error for value bound to another key")
+		}
+
+		unknown := encrypted
+		unknown.Password = "ENC[aes256gcm:missing:AAAA]"
+		if err := cfg.Update(ctx, unknown); err == nil {
+			t.Error("expected error for unknown key")
+		}
+	})
+}
@@ -2,17 +2,23 @@ package gorealconf
 
 import "fmt"
 
-// ValidationError represents a configuration validation error
+// ValidationError represents a configuration validation error. Old and New
+// are redacted copies of the configurations, see Redacted.
 type ValidationError struct {
 	Message string
 	Old     interface{}
 	New     interface{}
+	Cause   error
 }
 
 func (e *ValidationError) Error() string {
 	return fmt.Sprintf("validation failed: %s", e.Message)
 }
 
+func (e *ValidationError) Unwrap() error {
+	return e.Cause
+}
+
 // RollbackError represents a rollback failure
 type RollbackError struct {
 	Message string
@@ -22,3 +28,47 @@ type RollbackError struct {
 func (e *RollbackError) Error() string {
 	return fmt.Sprintf("rollback failed: %s: %v", e.Message, e.Cause)
 }
+
+// VersionConflictError is returned by Config.UpdateIfVersion when the
+// configuration changed since the expected version was read
+type VersionConflictError struct {
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict: expected %d, current %d", e.Expected, e.Actual)
+}
+
+// CASConflictError is returned by CASSource.CompareAndSwap when the stored
+// version is not the expected one
+type CASConflictError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *CASConflictError) Error() string {
+	expected, actual := e.Expected, e.Actual
+	if expected == "" {
+		expected = "none"
+	}
+	if actual == "" {
+		actual = "none"
+	}
+	return fmt.Sprintf("version conflict: expected %s, current %s", expected, actual)
+}
+
+// SignatureError is returned when a payload fails signature verification
+type SignatureError struct {
+	KeyID  string
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	if e.KeyID == "" {
+		return fmt.Sprintf("signature verification failed: %s", e.Reason)
+	}
+	return fmt.Sprintf("signature verification failed for key %s: %s", e.KeyID, e.Reason)
+}
+
+var errUnverifiableSource = &SignatureError{Reason: "source does not provide raw payloads"}
@@ -0,0 +1,228 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ExperimentVariant is one arm of an experiment. Weight is its share of
+// traffic relative to the other variants.
+type ExperimentVariant[T any] struct {
+	Name   string
+	Weight float64
+	Value  T
+}
+
+// VariantStats counts how often a variant was served
+type VariantStats struct {
+	Name      string  `json:"name"`
+	Weight    float64 `json:"weight"`
+	Exposures uint64  `json:"exposures"`
+}
+
+// ExperimentStatus is a snapshot of an experiment
+type ExperimentStatus struct {
+	Name      string         `json:"name"`
+	Concluded bool           `json:"concluded"`
+	Winner    string         `json:"winner,omitempty"`
+	Variants  []VariantStats `json:"variants"`
+}
+
+type experimentArm[T any] struct {
+	name      string
+	weight    float64
+	raw       T
+	value     T
+	exposures uint64
+}
+
+// Experiment splits traffic between N variants of a configuration. Each
+// subject is assigned a variant by weight and keeps it for the lifetime of
+// the experiment. Once a winner is declared it becomes the config's value.
+type Experiment[T any] struct {
+	config   *Config[T]
+	name     string
+	strategy RolloutStrategy
+	total    float64
+
+	mu         sync.Mutex
+	arms       []*experimentArm[T]
+	concluding bool // a winner is being applied
+	concluded  bool
+	winner     string
+}
+
+// NewExperiment validates and prepares the variants of experiment name. The
+// name also salts assignment, so experiments split subjects independently.
+func NewExperiment[T any](ctx context.Context, config *Config[T], name string, variants ...ExperimentVariant[T]) (*Experiment[T], error) {
+	if len(variants) < 2 {
+		return nil, errors.New("experiment needs at least two variants")
+	}
+
+	e := &Experiment[T]{
+		config: config,
+		name:   name,
+	}
+
+	stable := config.Get(ctx)
+	seen := make(map[string]bool, len(variants))
+	for _, v := range variants {
+		switch {
+		case v.Name == "" || v.Name == VariantStable:
+			return nil, fmt.Errorf("invalid variant name %q", v.Name)
+		case seen[v.Name]:
+			return nil, fmt.Errorf("duplicate variant %q", v.Name)
+		case v.Weight <= 0:
+			return nil, fmt.Errorf("variant %q must have a positive weight", v.Name)
+		}
+		seen[v.Name] = true
+
+		value, err := config.prepare(ctx, v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("variant %q: %w", v.Name, err)
+		}
+		if config.validator != nil {
+			if err := config.validator(stable, value); err != nil {
+				return nil, &ValidationError{
+					Message: fmt.Sprintf("variant %q: %v", v.Name, err),
+					Old:     Redacted(stable),
+					New:     Redacted(value),
+					Cause:   err,
+				}
+			}
+		}
+
+		e.arms = append(e.arms, &experimentArm[T]{
+			name:   v.Name,
+			weight: v.Weight,
+			raw:    v.Value,
+			value:  value,
+		})
+		e.total += v.Weight
+	}
+
+	return e, nil
+}
+
+// WithStrategy limits the experiment to subjects the strategy applies to.
+// Other subjects get the config's stable value.
+func (e *Experiment[T]) WithStrategy(strategy RolloutStrategy) *Experiment[T] {
+	e.strategy = strategy
+	return e
+}
+
+// Assign returns the variant subject is assigned to, or VariantStable when
+// the subject is not part of the experiment or it has concluded. Subjects
+// without an ID cannot be assigned consistently and get VariantStable.
+func (e *Experiment[T]) Assign(subject Subject) string {
+	e.mu.Lock()
+	concluded := e.concluded
+	e.mu.Unlock()
+
+	if concluded || subject.ID == "" || (e.strategy != nil && !applyTo(e.strategy, subject)) {
+		return VariantStable
+	}
+
+	point := float64(bucket(e.name, subject.ID)) / bucketCount * e.total
+	for _, arm := range e.arms {
+		if point < arm.weight {
+			return arm.name
+		}
+		point -= arm.weight
+	}
+	return e.arms[len(e.arms)-1].name
+}
+
+// Resolve returns the value to serve for the subject in ctx, see
+// ResolveVariant
+func (e *Experiment[T]) Resolve(ctx context.Context) T {
+	value, _ := e.ResolveVariant(ctx)
+	return value
+}
+
+// ResolveVariant returns the value of the variant assigned to the subject
+// in ctx and records an exposure. Requests without a subject get the
+// config's stable value.
+func (e *Experiment[T]) ResolveVariant(ctx context.Context) (T, string) {
+	variant := VariantStable
+	if subject, ok := SubjectFromContext(ctx); ok {
+		variant = e.Assign(subject)
+	}
+
+	e.mu.Lock()
+	var arm *experimentArm[T]
+	if !e.concluded {
+		for _, a := range e.arms {
+			if a.name == variant {
+				arm = a
+				arm.exposures++
+				break
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	if arm == nil {
+		return e.config.Get(ctx), VariantStable
+	}
+	e.config.metrics.IncExperimentExposure(e.name, variant)
+	return arm.value, variant
+}
+
+// DeclareWinner applies the named variant to the config and ends the
+// experiment, after which every subject gets the new stable value
+func (e *Experiment[T]) DeclareWinner(ctx context.Context, name string) error {
+	e.mu.Lock()
+	if e.concluded || e.concluding {
+		e.mu.Unlock()
+		return errors.New("experiment already concluded")
+	}
+	var winner *experimentArm[T]
+	for _, arm := range e.arms {
+		if arm.name == name {
+			winner = arm
+		}
+	}
+	if winner == nil {
+		e.mu.Unlock()
+		return fmt.Errorf("unknown variant %q", name)
+	}
+	// Claim the experiment so a concurrent call cannot apply another winner
+	e.concluding = true
+	e.mu.Unlock()
+
+	err := e.config.update(ctx, winner.raw, nil, SourceExperiment, 0)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.concluding = false
+	if err != nil {
+		return err
+	}
+	e.concluded = true
+	e.winner = name
+	return nil
+}
+
+// Status returns the experiment's variants and their exposures
+func (e *Experiment[T]) Status() ExperimentStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := ExperimentStatus{
+		Name:      e.name,
+		Concluded: e.concluded,
+		Winner:    e.winner,
+		Variants:  make([]VariantStats, 0, len(e.arms)),
+	}
+	for _, arm := range e.arms {
+		status.Variants = append(status.Variants, VariantStats{
+			Name:      arm.name,
+			Weight:    arm.weight,
+			Exposures: arm.exposures,
+		})
+	}
+	return status
+}
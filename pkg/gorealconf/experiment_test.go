@@ -0,0 +1,172 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestExperiment(t *testing.T) {
+	type TestConfig struct {
+		Layout string
+	}
+
+	ctx := context.Background()
+	metrics := NewMetrics("test")
+	cfg := New[TestConfig](WithMetrics[TestConfig](metrics))
+	cfg.Update(ctx, TestConfig{Layout: "classic"})
+
+	t.Run("invalid variants", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			variants []ExperimentVariant[TestConfig]
+		}{
+			{"single", []ExperimentVariant[TestConfig]{{Name: "a", Weight: 1}}},
+			{"duplicate", []ExperimentVariant[TestConfig]{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}},
+			{"zero weight", []ExperimentVariant[TestConfig]{{Name: "a", Weight: 1}, {Name: "b"}}},
+			{"reserved name", []ExperimentVariant[TestConfig]{{Name: "a", Weight: 1}, {Name: VariantStable, Weight: 1}}},
+		}
+		for _, tt := range tests {
+			if _, err := NewExperiment(ctx, cfg, "checkout", tt.variants...); err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+		}
+	})
+
+	experiment, err := NewExperiment(ctx, cfg, "checkout",
+		ExperimentVariant[TestConfig]{Name: "a", Weight: 50, Value: TestConfig{Layout: "classic"}},
+		ExperimentVariant[TestConfig]{Name: "b", Weight: 30, Value: TestConfig{Layout: "grid"}},
+		ExperimentVariant[TestConfig]{Name: "c", Weight: 20, Value: TestConfig{Layout: "list"}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("weighted sticky assignment", func(t *testing.T) {
+		counts := map[string]int{}
+		for i := 0; i < 10000; i++ {
+			subject := Subject{ID: fmt.Sprintf("user-%d", i)}
+			variant := experiment.Assign(subject)
+			if experiment.Assign(subject) != variant {
+				t.Fatalf("assignment for %s is not sticky", subject.ID)
+			}
+			counts[variant]++
+		}
+
+		want := map[string]int{"a": 5000, "b": 3000, "c": 2000}
+		for variant, n := range want {
+			if counts[variant] < n-300 || counts[variant] > n+300 {
+				t.Errorf("variant %s: expected about %d subjects, got %d", variant, n, counts[variant])
+			}
+		}
+	})
+
+	t.Run("exposures", func(t *testing.T) {
+		layouts := map[string]string{"a": "classic", "b": "grid", "c": "list"}
+		for i := 0; i < 100; i++ {
+			value, variant := experiment.ResolveVariant(WithSubject(ctx, Subject{ID: fmt.Sprintf("user-%d", i)}))
+			if value.Layout != layouts[variant] {
+				t.Fatalf("variant %s served layout %q", variant, value.Layout)
+			}
+		}
+		if _, variant := experiment.ResolveVariant(ctx); variant != VariantStable {
+			t.Errorf("expected stable without a subject, got %s", variant)
+		}
+		if _, variant := experiment.ResolveVariant(WithSubject(ctx, Subject{Attributes: map[string]string{"tier": "beta"}})); variant != VariantStable {
+			t.Errorf("expected stable for a subject without an ID, got %s", variant)
+		}
+
+		var total uint64
+		for _, v := range experiment.Status().Variants {
+			total += v.Exposures
+			if got := testutil.ToFloat64(metrics.exposures.WithLabelValues("checkout", v.Name)); got != float64(v.Exposures) {
+				t.Errorf("variant %s: expected metric %d, got %v", v.Name, v.Exposures, got)
+			}
+		}
+		if total != 100 {
+			t.Errorf("expected 100 exposures, got %d", total)
+		}
+	})
+
+	t.Run("targeted", func(t *testing.T) {
+		rule, _ := NewRuleStrategy(Rule{Attribute: "country", Operator: OpEquals, Value: "NL"})
+		targeted, err := NewExperiment(ctx, cfg, "targeted",
+			ExperimentVariant[TestConfig]{Name: "a", Weight: 1, Value: TestConfig{Layout: "grid"}},
+			ExperimentVariant[TestConfig]{Name: "b", Weight: 1, Value: TestConfig{Layout: "list"}},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		targeted.WithStrategy(rule)
+
+		if variant := targeted.Assign(Subject{ID: "x", Attributes: map[string]string{"country": "DE"}}); variant != VariantStable {
+			t.Errorf("expected untargeted subject to get stable, got %s", variant)
+		}
+	})
+
+	t.Run("winner", func(t *testing.T) {
+		if err := experiment.DeclareWinner(ctx, "missing"); err == nil {
+			t.Error("expected error for unknown variant")
+		}
+		if err := experiment.DeclareWinner(ctx, "b"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := cfg.Get(ctx).Layout; got != "grid" {
+			t.Errorf("expected winner to become stable, got %q", got)
+		}
+		value, variant := experiment.ResolveVariant(WithSubject(ctx, Subject{ID: "user-1"}))
+		if variant != VariantStable || value.Layout != "grid" {
+			t.Errorf("expected everyone to get the winner, got %s %q", variant, value.Layout)
+		}
+		if status := experiment.Status(); !status.Concluded || status.Winner != "b" {
+			t.Errorf("unexpected status %+v", status)
+		}
+	})
+	t.Run("concurrent winners", func(t *testing.T) {
+		var reject atomic.Bool
+		cfg := New[TestConfig](WithRollback[TestConfig](true), WithValidation(func(_, _ TestConfig) error {
+			time.Sleep(10 * time.Millisecond)
+			if reject.Load() {
+				return errors.New("rejected")
+			}
+			return nil
+		}))
+		experiment, err := NewExperiment(ctx, cfg, "winners",
+			ExperimentVariant[TestConfig]{Name: "a", Weight: 1, Value: TestConfig{Layout: "grid"}},
+			ExperimentVariant[TestConfig]{Name: "b", Weight: 1, Value: TestConfig{Layout: "list"}},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// A failed update leaves the experiment running
+		reject.Store(true)
+		if err := experiment.DeclareWinner(ctx, "a"); err == nil {
+			t.Fatal("expected the rejected winner to fail")
+		}
+		reject.Store(false)
+
+		errs := make(chan error, 2)
+		for _, name := range []string{"a", "b"} {
+			go func() { errs <- experiment.DeclareWinner(ctx, name) }()
+		}
+		declared := 0
+		for range 2 {
+			if err := <-errs; err == nil {
+				declared++
+			}
+		}
+		if declared != 1 {
+			t.Errorf("expected exactly one winner, got %d", declared)
+		}
+		if got := cfg.Version(); got != 1 {
+			t.Errorf("expected a single update, got version %d", got)
+		}
+	})
+}
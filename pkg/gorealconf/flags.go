@@ -0,0 +1,336 @@
+package gorealconf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Flag evaluation reasons
+const (
+	ReasonDisabled       = "DISABLED"        // the flag is turned off
+	ReasonTargetingMatch = "TARGETING_MATCH" // a targeting rule matched
+	ReasonSplit          = "SPLIT"           // the subject was bucketed into a weighted split
+	ReasonStatic         = "STATIC"          // every subject gets the same variant
+	ReasonDefault        = "DEFAULT"         // the default variant was served
+	ReasonError          = "ERROR"           // the flag could not be evaluated
+)
+
+// Default variants of boolean flags
+const (
+	FlagOn  = "on"
+	FlagOff = "off"
+)
+
+// FlagDocument holds every flag of an application. Load it from any source
+// with LoadFlags.
+type FlagDocument struct {
+	Flags map[string]Flag `json:"flags"`
+}
+
+// Flag is a feature flag. A flag without variants is boolean, with the
+// variants "on" (true) and "off" (false).
+//
+//	{"enabled": true,
+//	 "rules": [{"match": {"attribute": "tier", "op": "eq", "value": "beta"}, "variant": "on"}],
+//	 "fallthrough": {"rollout": [{"variant": "on", "weight": 10}, {"variant": "off", "weight": 90}]}}
+type Flag struct {
+	Description string         `json:"description,omitempty"`
+	Enabled     bool           `json:"enabled"`
+	Variants    map[string]any `json:"variants,omitempty"`
+	// DefaultVariant is served while the flag is disabled and to subjects
+	// that cannot be bucketed. It defaults to "off".
+	DefaultVariant string `json:"default_variant,omitempty"`
+	// Rules are evaluated in order; the first match decides
+	Rules []FlagRule `json:"rules,omitempty"`
+	// Fallthrough serves subjects no rule matched. It defaults to "on".
+	Fallthrough FlagServe `json:"fallthrough"`
+	// Salt changes bucketing; it defaults to the flag key
+	Salt string `json:"salt,omitempty"`
+}
+
+// FlagServe serves either a single variant or a weighted, sticky split
+type FlagServe struct {
+	Variant string       `json:"variant,omitempty"`
+	Rollout []FlagWeight `json:"rollout,omitempty"`
+}
+
+// FlagWeight is a variant's share of a split
+type FlagWeight struct {
+	Variant string  `json:"variant"`
+	Weight  float64 `json:"weight"`
+}
+
+// FlagRule serves subjects matching a targeting rule
+type FlagRule struct {
+	Match Rule `json:"match"`
+	FlagServe
+}
+
+// FlagEvaluation is the result of evaluating a flag for a subject
+type FlagEvaluation struct {
+	Key     string
+	Variant string
+	Value   any
+	Reason  string
+	Error   error
+}
+
+var booleanVariants = map[string]any{FlagOn: true, FlagOff: false}
+
+func (f Flag) variants() map[string]any {
+	if len(f.Variants) == 0 {
+		return booleanVariants
+	}
+	return f.Variants
+}
+
+func (f Flag) defaultVariant() string {
+	if f.DefaultVariant == "" {
+		return FlagOff
+	}
+	return f.DefaultVariant
+}
+
+// Validate checks that every variant the flag serves exists and that its
+// rules are well formed
+func (f Flag) Validate() error {
+	variants := f.variants()
+	known := func(name string) error {
+		if _, ok := variants[name]; !ok {
+			return fmt.Errorf("unknown variant %q", name)
+		}
+		return nil
+	}
+	serve := func(s FlagServe) error {
+		if s.Variant != "" {
+			return known(s.Variant)
+		}
+		for _, w := range s.Rollout {
+			if w.Weight < 0 {
+				return fmt.Errorf("variant %q has a negative weight", w.Variant)
+			}
+			if err := known(w.Variant); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := known(f.defaultVariant()); err != nil {
+		return err
+	}
+	for i, rule := range f.Rules {
+		// Through the slice, so the rule keeps what Validate prepares
+		if err := f.Rules[i].Match.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		if rule.Variant == "" && len(rule.Rollout) == 0 {
+			return fmt.Errorf("rule %d serves no variant", i)
+		}
+		if err := serve(rule.FlagServe); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	if err := serve(f.Fallthrough); err != nil {
+		return fmt.Errorf("fallthrough: %w", err)
+	}
+	if f.Fallthrough.Variant == "" && len(f.Fallthrough.Rollout) == 0 {
+		return known(FlagOn)
+	}
+	return nil
+}
+
+// Validate checks every flag in the document
+func (d FlagDocument) Validate() error {
+	for key, flag := range d.Flags {
+		if err := flag.Validate(); err != nil {
+			return fmt.Errorf("flag %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Evaluate resolves the flag for subject. key salts bucketing unless the
+// flag sets its own salt.
+func (f Flag) Evaluate(key string, subject Subject) FlagEvaluation {
+	eval := FlagEvaluation{Key: key}
+	if !f.Enabled {
+		return f.serveVariant(eval, f.defaultVariant(), ReasonDisabled)
+	}
+
+	salt := f.Salt
+	if salt == "" {
+		salt = key
+	}
+
+	for _, rule := range f.Rules {
+		if rule.Match.Matches(subject) {
+			eval = f.serve(eval, rule.FlagServe, salt, subject)
+			if eval.Reason == ReasonStatic {
+				eval.Reason = ReasonTargetingMatch
+			}
+			return eval
+		}
+	}
+
+	fallback := f.Fallthrough
+	if fallback.Variant == "" && len(fallback.Rollout) == 0 {
+		fallback.Variant = FlagOn
+	}
+	return f.serve(eval, fallback, salt, subject)
+}
+
+func (f Flag) serve(eval FlagEvaluation, s FlagServe, salt string, subject Subject) FlagEvaluation {
+	if s.Variant != "" {
+		return f.serveVariant(eval, s.Variant, ReasonStatic)
+	}
+
+	var total float64
+	for _, w := range s.Rollout {
+		total += w.Weight
+	}
+	if subject.ID == "" || total <= 0 {
+		// Without an ID the subject cannot be bucketed consistently
+		return f.serveVariant(eval, f.defaultVariant(), ReasonDefault)
+	}
+
+	point := float64(bucket(salt, subject.ID)) / bucketCount * total
+	for _, w := range s.Rollout {
+		if point < w.Weight {
+			return f.serveVariant(eval, w.Variant, ReasonSplit)
+		}
+		point -= w.Weight
+	}
+	return f.serveVariant(eval, s.Rollout[len(s.Rollout)-1].Variant, ReasonSplit)
+}
+
+func (f Flag) serveVariant(eval FlagEvaluation, variant, reason string) FlagEvaluation {
+	value, ok := f.variants()[variant]
+	if !ok {
+		eval.Reason = ReasonError
+		eval.Error = fmt.Errorf("flag %s: unknown variant %q", eval.Key, variant)
+		return eval
+	}
+	eval.Variant = variant
+	eval.Value = value
+	eval.Reason = reason
+	return eval
+}
+
+// FlagNotFoundError is reported when a flag is not defined
+type FlagNotFoundError struct {
+	Key string
+}
+
+func (e *FlagNotFoundError) Error() string {
+	return fmt.Sprintf("flag %s not found", e.Key)
+}
+
+// UnknownFlagLabel is the flag label of evaluations of undefined flags in
+// metrics
+const UnknownFlagLabel = "unknown"
+
+// Flags evaluates feature flags from a FlagDocument held by a Config, so
+// flag changes from its sources apply without a restart
+type Flags struct {
+	config *Config[FlagDocument]
+
+	mu        sync.RWMutex
+	listeners []func(ctx context.Context, eval FlagEvaluation)
+}
+
+func NewFlags(config *Config[FlagDocument]) *Flags {
+	return &Flags{config: config}
+}
+
+// LoadFlags loads the flag document from a Config built with opts,
+// typically WithSource. Invalid documents are rejected and the previous
+// flags stay in effect.
+func LoadFlags(ctx context.Context, opts ...Option[FlagDocument]) (*Flags, error) {
+	opts = append(opts,
+		WithValidation(func(_, doc FlagDocument) error { return doc.Validate() }),
+		WithRollback[FlagDocument](true),
+	)
+
+	config := New(opts...)
+	if err := config.Load(ctx); err != nil {
+		return nil, err
+	}
+	return NewFlags(config), nil
+}
+
+// Config returns the config holding the flag document
+func (f *Flags) Config() *Config[FlagDocument] {
+	return f.config
+}
+
+// OnEvaluation registers a listener called after every evaluation, for
+// example to record exposures in an analytics pipeline
+func (f *Flags) OnEvaluation(listener func(ctx context.Context, eval FlagEvaluation)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listeners = append(f.listeners, listener)
+}
+
+// Evaluate resolves flag key for the subject in ctx
+func (f *Flags) Evaluate(ctx context.Context, key string) FlagEvaluation {
+	subject, _ := SubjectFromContext(ctx)
+
+	var eval FlagEvaluation
+	label := key
+	flag, ok := f.config.Get(ctx).Flags[key]
+	if ok {
+		eval = flag.Evaluate(key, subject)
+	} else {
+		eval = FlagEvaluation{Key: key, Reason: ReasonError, Error: &FlagNotFoundError{Key: key}}
+		// Keys come from callers, so only defined flags get their own series
+		label = UnknownFlagLabel
+	}
+
+	f.config.metrics.IncFlagEvaluation(label, eval.Variant)
+
+	f.mu.RLock()
+	listeners := f.listeners
+	f.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(ctx, eval)
+	}
+
+	return eval
+}
+
+// Bool returns the value of a boolean flag, or def when the flag is not
+// defined or its value is not a bool
+func (f *Flags) Bool(ctx context.Context, key string, def bool) bool {
+	if value, ok := f.Evaluate(ctx, key).Value.(bool); ok {
+		return value
+	}
+	return def
+}
+
+// String returns the value of a string flag, or def
+func (f *Flags) String(ctx context.Context, key string, def string) string {
+	if value, ok := f.Evaluate(ctx, key).Value.(string); ok {
+		return value
+	}
+	return def
+}
+
+// Float returns the value of a numeric flag, or def
+func (f *Flags) Float(ctx context.Context, key string, def float64) float64 {
+	switch value := f.Evaluate(ctx, key).Value.(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	default:
+		return def
+	}
+}
+
+// Variant returns the name of the variant served for flag key, or "" when
+// the flag cannot be evaluated
+func (f *Flags) Variant(ctx context.Context, key string) string {
+	return f.Evaluate(ctx, key).Variant
+}
@@ -0,0 +1,181 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const testFlags = `{"flags": {
+  "new_ui": {
+    "enabled": true,
+    "rules": [{"match": {"attribute": "tier", "op": "eq", "value": "beta"}, "variant": "on"}],
+    "fallthrough": {"rollout": [{"variant": "on", "weight": 20}, {"variant": "off", "weight": 80}]}
+  },
+  "checkout": {
+    "enabled": true,
+    "variants": {"control": "one-page", "treatment": "multi-step"},
+    "default_variant": "control",
+    "fallthrough": {"variant": "treatment"}
+  },
+  "max_items": {
+    "enabled": true,
+    "variants": {"small": 10, "large": 50},
+    "default_variant": "small",
+    "fallthrough": {"variant": "large"}
+  },
+  "kill_switch": {"enabled": false}
+}}`
+
+func TestFlags(t *testing.T) {
+	dir := t.TempDir()
+	flagsPath := filepath.Join(dir, "flags.json")
+	if err := os.WriteFile(flagsPath, []byte(testFlags), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	source, err := NewFileSource[FlagDocument](flagsPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flags, err := LoadFlags(ctx, WithSource[FlagDocument](source))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var events []FlagEvaluation
+	flags.OnEvaluation(func(ctx context.Context, eval FlagEvaluation) {
+		events = append(events, eval)
+	})
+
+	beta := WithSubject(ctx, Subject{ID: "user-1", Attributes: map[string]string{"tier": "beta"}})
+
+	t.Run("evaluate", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			ctx         context.Context
+			key         string
+			wantVariant string
+			wantReason  string
+		}{
+			{"targeting", beta, "new_ui", FlagOn, ReasonTargetingMatch},
+			{"split", WithSubject(ctx, Subject{ID: "user-2"}), "new_ui", "", ReasonSplit},
+			{"no subject", ctx, "new_ui", FlagOff, ReasonDefault},
+			{"static", ctx, "checkout", "treatment", ReasonStatic},
+			{"disabled", ctx, "kill_switch", FlagOff, ReasonDisabled},
+			{"missing", ctx, "unknown", "", ReasonError},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				eval := flags.Evaluate(tt.ctx, tt.key)
+				if eval.Reason != tt.wantReason {
+					t.Errorf("expected reason %s, got %s", tt.wantReason, eval.Reason)
+				}
+				if tt.wantVariant != "" && eval.Variant != tt.wantVariant {
+					t.Errorf("expected variant %s, got %s", tt.wantVariant, eval.Variant)
+				}
+			})
+		}
+
+		var notFound *FlagNotFoundError
+		if !errors.As(flags.Evaluate(ctx, "unknown").Error, &notFound) {
+			t.Error("expected *FlagNotFoundError")
+		}
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		if !flags.Bool(beta, "new_ui", false) {
+			t.Error("expected new_ui on for beta")
+		}
+		if !flags.Bool(ctx, "unknown", true) {
+			t.Error("expected default for unknown flag")
+		}
+		if got := flags.String(ctx, "checkout", "fallback"); got != "multi-step" {
+			t.Errorf("expected multi-step, got %q", got)
+		}
+		if got := flags.String(ctx, "new_ui", "fallback"); got != "fallback" {
+			t.Errorf("expected default for mistyped flag, got %q", got)
+		}
+		if got := flags.Float(ctx, "max_items", 1); got != 50 {
+			t.Errorf("expected 50, got %v", got)
+		}
+		if got := flags.Variant(ctx, "checkout"); got != "treatment" {
+			t.Errorf("expected treatment, got %q", got)
+		}
+	})
+
+	t.Run("sticky split", func(t *testing.T) {
+		on := 0
+		for i := 0; i < 1000; i++ {
+			subject := WithSubject(ctx, Subject{ID: fmt.Sprintf("user-%d", i)})
+			value := flags.Bool(subject, "new_ui", false)
+			if flags.Bool(subject, "new_ui", false) != value {
+				t.Fatalf("user-%d flipped between evaluations", i)
+			}
+			if value {
+				on++
+			}
+		}
+		if on < 150 || on > 250 {
+			t.Errorf("expected about 20%% on, got %d of 1000", on)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		events = nil
+		flags.Bool(beta, "new_ui", false)
+		if len(events) != 1 || events[0].Key != "new_ui" || events[0].Variant != FlagOn {
+			t.Errorf("unexpected events %+v", events)
+		}
+	})
+
+	t.Run("hot reload", func(t *testing.T) {
+		// Invalid documents are rejected
+		os.WriteFile(flagsPath, []byte(`{"flags": {"kill_switch": {"enabled": true, "fallthrough": {"variant": "maybe"}}}}`), 0o600)
+		time.Sleep(200 * time.Millisecond)
+		if flags.Bool(ctx, "kill_switch", true) {
+			t.Fatal("expected invalid document to be rejected")
+		}
+
+		os.WriteFile(flagsPath, []byte(`{"flags": {"kill_switch": {"enabled": true}}}`), 0o600)
+		deadline := time.Now().Add(2 * time.Second)
+		for !flags.Bool(ctx, "kill_switch", false) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if !flags.Bool(ctx, "kill_switch", false) {
+			t.Error("expected kill_switch to be turned on")
+		}
+	})
+}
+
+func TestFlagMetrics(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewMetrics("test")
+	cfg := New[FlagDocument](WithMetrics[FlagDocument](metrics))
+	if err := cfg.Update(ctx, FlagDocument{Flags: map[string]Flag{"kill_switch": {}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	flags := NewFlags(cfg)
+
+	for i := 0; i < 3; i++ {
+		flags.Evaluate(ctx, fmt.Sprintf("missing-%d", i))
+	}
+	flags.Evaluate(ctx, "kill_switch")
+
+	if got := testutil.CollectAndCount(metrics.flagEvals); got != 2 {
+		t.Errorf("expected 2 series, got %d", got)
+	}
+	if got := testutil.ToFloat64(metrics.flagEvals.WithLabelValues(UnknownFlagLabel, "")); got != 3 {
+		t.Errorf("expected 3 unknown evaluations, got %v", got)
+	}
+}
@@ -0,0 +1,194 @@
+package gorealconf
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// grpcCodecName is the content subtype used by the config service. Messages
+// are plain JSON, so no generated protobuf code is required.
+const grpcCodecName = "gorealconf-json"
+
+const (
+	grpcServiceName = "gorealconf.v1.ConfigService"
+	grpcGetMethod   = "/" + grpcServiceName + "/Get"
+	grpcWatchMethod = "/" + grpcServiceName + "/Watch"
+)
+
+func init() {
+	encoding.RegisterCodec(grpcJSONCodec{})
+}
+
+type grpcJSONCodec struct{}
+
+func (grpcJSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (grpcJSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (grpcJSONCodec) Name() string {
+	return grpcCodecName
+}
+
+// WatchRequest asks the config service for updates newer than FromVersion.
+// FromVersion is ignored when Epoch does not match the server's epoch, e.g.
+// after the server restarted and its versions began again from zero.
+type WatchRequest struct {
+	Epoch       string `json:"epoch,omitempty"`
+	FromVersion uint64 `json:"from_version"`
+}
+
+// ConfigUpdate carries a configuration value and the version it was
+// applied at on the serving Config
+type ConfigUpdate struct {
+	Epoch   string          `json:"epoch"`
+	Version uint64          `json:"version"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type configServiceServer interface {
+	get(ctx context.Context, req *WatchRequest) (*ConfigUpdate, error)
+	watch(req *WatchRequest, stream grpc.ServerStream) error
+}
+
+var configServiceDesc = grpc.ServiceDesc{
+	ServiceName: grpcServiceName,
+	HandlerType: (*configServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    configServiceGetHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       configServiceWatchHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+func configServiceGetHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(WatchRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	server := srv.(configServiceServer)
+	if interceptor == nil {
+		return server.get(ctx, req)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: grpcGetMethod,
+	}
+	return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return server.get(ctx, req.(*WatchRequest))
+	})
+}
+
+func configServiceWatchHandler(srv any, stream grpc.ServerStream) error {
+	req := new(WatchRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(configServiceServer).watch(req, stream)
+}
+
+// GRPCServer serves a Config to GRPCSource clients, so a single process
+// can fan configuration out to a fleet
+type GRPCServer[T any] struct {
+	config *Config[T]
+	epoch  string
+}
+
+func NewGRPCServer[T any](config *Config[T]) *GRPCServer[T] {
+	var epoch [8]byte
+	_, _ = rand.Read(epoch[:])
+
+	return &GRPCServer[T]{
+		config: config,
+		epoch:  hex.EncodeToString(epoch[:]),
+	}
+}
+
+// Register registers the config service on a gRPC server
+func (s *GRPCServer[T]) Register(registrar grpc.ServiceRegistrar) {
+	registrar.RegisterService(&configServiceDesc, s)
+}
+
+func (s *GRPCServer[T]) get(ctx context.Context, req *WatchRequest) (*ConfigUpdate, error) {
+	value, version := s.config.snapshot()
+	return s.newConfigUpdate(value, version)
+}
+
+func (s *GRPCServer[T]) watch(req *WatchRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	changes, cleanup := s.config.Subscribe(ctx)
+	defer cleanup()
+
+	var sent uint64
+	if req.Epoch == s.epoch {
+		sent = req.FromVersion
+	}
+	send := func() error {
+		value, version := s.config.snapshot()
+		if version <= sent {
+			return nil
+		}
+		update, err := s.newConfigUpdate(value, version)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(update); err != nil {
+			return err
+		}
+		sent = version
+		return nil
+	}
+
+	// Catch the client up before waiting for changes
+	if err := send(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-changes:
+			if !ok {
+				// The subscriber fell behind and was dropped; the client
+				// resumes from the last version it received
+				return status.Error(codes.Unavailable, "subscriber dropped")
+			}
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *GRPCServer[T]) newConfigUpdate(value T, version uint64) (*ConfigUpdate, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode config: %v", err)
+	}
+	return &ConfigUpdate{
+		Epoch:   s.epoch,
+		Version: version,
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package gorealconf
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCDistribution(t *testing.T) {
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := New[TestConfig]()
+	if err := cfg.Update(ctx, TestConfig{Value: "v1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	NewGRPCServer(cfg).Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	source, err := NewGRPCSource[TestConfig]("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer source.Close()
+
+	value, err := source.Load(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value.Value != "v1" || source.Version() != 1 {
+		t.Errorf("expected v1 at version 1, got %q at version %d", value.Value, source.Version())
+	}
+
+	handler := newRecordHandler()
+	changes, err := source.Watch(contextWithLogger(ctx, slog.New(handler)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The stream resumes from the loaded version, so v1 is not resent
+	if err := cfg.Update(ctx, TestConfig{Value: "v2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case value := <-changes:
+		if value.Value != "v2" {
+			t.Errorf("expected v2, got %q", value.Value)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for update")
+	}
+
+	if got := source.Version(); got != 2 {
+		t.Errorf("expected version 2, got %d", got)
+	}
+
+	// A broken stream is logged and retried
+	server.Stop()
+	if rec := handler.find(t, "grpc watch error"); rec["error"] == nil {
+		t.Errorf("expected the stream error to be logged, got %v", rec)
+	}
+}
@@ -0,0 +1,263 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotEnoughData is returned by a HealthSignal that cannot judge the
+// variants yet. Rollouts make no decision on it.
+var ErrNotEnoughData = errors.New("not enough data")
+
+// HealthSignal reports the error rates of the stable and candidate
+// variants of a rollout, as fractions between 0 and 1
+type HealthSignal interface {
+	ErrorRates(ctx context.Context) (stable, candidate float64, err error)
+}
+
+// errorRateBuckets is the number of buckets a sliding window is split into
+const errorRateBuckets = 10
+
+type rateBucket struct {
+	epoch    int64
+	requests uint64
+	errors   uint64
+}
+
+// ErrorRateCounter counts requests and errors per variant over a sliding
+// window. Record outcomes with the variant returned by
+// Rollout.ResolveVariant and pass the counter to Rollout.WithHealthSignal.
+type ErrorRateCounter struct {
+	mu          sync.Mutex
+	width       time.Duration
+	minRequests uint64
+	variants    map[string]*[errorRateBuckets]rateBucket
+	now         func() time.Time
+}
+
+func NewErrorRateCounter(window time.Duration) *ErrorRateCounter {
+	width := window / errorRateBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &ErrorRateCounter{
+		width:       width,
+		minRequests: defaultMinRequests,
+		variants:    make(map[string]*[errorRateBuckets]rateBucket),
+		now:         time.Now,
+	}
+}
+
+// WithMinRequests sets how many requests each variant needs within the
+// window before ErrorRates reports them. The default is 100.
+func (c *ErrorRateCounter) WithMinRequests(n uint64) *ErrorRateCounter {
+	c.minRequests = n
+	return c
+}
+
+// Record counts a request served with variant. Pass a nil error for a
+// successful request.
+func (c *ErrorRateCounter) Record(variant string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buckets, ok := c.variants[variant]
+	if !ok {
+		buckets = new([errorRateBuckets]rateBucket)
+		c.variants[variant] = buckets
+	}
+
+	epoch := c.now().UnixNano() / int64(c.width)
+	b := &buckets[epoch%errorRateBuckets]
+	if b.epoch != epoch {
+		*b = rateBucket{epoch: epoch}
+	}
+	b.requests++
+	if err != nil {
+		b.errors++
+	}
+}
+
+// Rate returns the error rate of variant within the window and the number
+// of requests it is based on
+func (c *ErrorRateCounter) Rate(variant string) (float64, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buckets, ok := c.variants[variant]
+	if !ok {
+		return 0, 0
+	}
+
+	oldest := c.now().UnixNano()/int64(c.width) - errorRateBuckets + 1
+	var requests, errs uint64
+	for _, b := range buckets {
+		if b.epoch >= oldest {
+			requests += b.requests
+			errs += b.errors
+		}
+	}
+	if requests == 0 {
+		return 0, 0
+	}
+	return float64(errs) / float64(requests), requests
+}
+
+// ErrorRates implements HealthSignal for the stable and candidate variants
+func (c *ErrorRateCounter) ErrorRates(ctx context.Context) (float64, float64, error) {
+	stable, stableRequests := c.Rate(VariantStable)
+	candidate, candidateRequests := c.Rate(VariantCandidate)
+	if candidateRequests < c.minRequests || stableRequests < c.minRequests {
+		return 0, 0, ErrNotEnoughData
+	}
+	return stable, candidate, nil
+}
+
+// PrometheusSignal reads the variants' error rates from Prometheus with an
+// instant query per variant, for example
+//
+//	sum(rate(http_errors_total{variant="candidate"}[5m])) / sum(rate(http_requests_total{variant="candidate"}[5m]))
+type PrometheusSignal struct {
+	address        string
+	stableQuery    string
+	candidateQuery string
+	client         *http.Client
+}
+
+func NewPrometheusSignal(address, stableQuery, candidateQuery string) *PrometheusSignal {
+	return &PrometheusSignal{
+		address:        address,
+		stableQuery:    stableQuery,
+		candidateQuery: candidateQuery,
+		client:         &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// WithClient sets the HTTP client used to query Prometheus
+func (s *PrometheusSignal) WithClient(client *http.Client) *PrometheusSignal {
+	s.client = client
+	return s
+}
+
+func (s *PrometheusSignal) ErrorRates(ctx context.Context) (float64, float64, error) {
+	stable, err := s.query(ctx, s.stableQuery)
+	if err != nil {
+		return 0, 0, err
+	}
+	candidate, err := s.query(ctx, s.candidateQuery)
+	if err != nil {
+		return 0, 0, err
+	}
+	return stable, candidate, nil
+}
+
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// query runs an instant query returning a scalar or a single-sample vector
+func (s *PrometheusSignal) query(ctx context.Context, query string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		s.address+"/api/v1/query?"+url.Values{"query": {query}}.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var body prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("invalid prometheus response: %w", err)
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s", body.Error)
+	}
+
+	var sample []any
+	switch body.Data.ResultType {
+	case "scalar":
+		err = json.Unmarshal(body.Data.Result, &sample)
+	case "vector":
+		var vector []struct {
+			Value []any `json:"value"`
+		}
+		err = json.Unmarshal(body.Data.Result, &vector)
+		if err == nil && len(vector) == 0 {
+			return 0, ErrNotEnoughData
+		}
+		if err == nil {
+			sample = vector[0].Value
+		}
+	default:
+		return 0, fmt.Errorf("unsupported prometheus result type %q", body.Data.ResultType)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid prometheus result: %w", err)
+	}
+
+	if len(sample) != 2 {
+		return 0, errors.New("invalid prometheus sample")
+	}
+	text, _ := sample[1].(string)
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid prometheus sample value %q", text)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, ErrNotEnoughData
+	}
+	return value, nil
+}
+
+// WithHealthSignal rolls the candidate back when a signal reports its error
+// rate exceeding the stable error rate by more than the rollback
+// threshold. Signals are polled at the health check interval.
+func (r *Rollout[T]) WithHealthSignal(signal HealthSignal) *Rollout[T] {
+	r.signals = append(r.signals, signal)
+	return r
+}
+
+// monitor polls the health signals until the rollout finishes
+func (r *Rollout[T]) monitor(ctx context.Context, abort chan<- string, done <-chan struct{}) {
+	ticker := time.NewTicker(r.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for _, signal := range r.signals {
+			stable, candidate, err := signal.ErrorRates(ctx)
+			if err != nil || candidate-stable <= r.threshold {
+				continue
+			}
+			select {
+			case abort <- fmt.Sprintf("error rate %.4f exceeded stable %.4f by more than %.4f", candidate, stable, r.threshold):
+			default:
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestErrorRateCounter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	counter := NewErrorRateCounter(time.Minute).WithMinRequests(10)
+	counter.now = func() time.Time { return now }
+
+	record := func(variant string, requests, errs int) {
+		for i := 0; i < requests; i++ {
+			var err error
+			if i < errs {
+				err = errors.New("failed")
+			}
+			counter.Record(variant, err)
+		}
+	}
+
+	record(VariantStable, 5, 0)
+	record(VariantCandidate, 5, 5)
+	if _, _, err := counter.ErrorRates(context.Background()); !errors.Is(err, ErrNotEnoughData) {
+		t.Errorf("expected ErrNotEnoughData, got %v", err)
+	}
+
+	record(VariantStable, 95, 1)
+	record(VariantCandidate, 15, 0)
+	stable, candidate, err := counter.ErrorRates(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stable != 0.01 || candidate != 0.25 {
+		t.Errorf("expected 0.01 and 0.25, got %v and %v", stable, candidate)
+	}
+
+	// Old buckets slide out of the window
+	now = now.Add(30 * time.Second)
+	record(VariantCandidate, 10, 0)
+	if rate, requests := counter.Rate(VariantCandidate); requests != 30 || rate != 5.0/30 {
+		t.Errorf("expected 5 errors in 30 requests, got %v of %d", rate, requests)
+	}
+
+	now = now.Add(45 * time.Second)
+	if rate, requests := counter.Rate(VariantCandidate); requests != 10 || rate != 0 {
+		t.Errorf("expected only the last 10 requests, got %v of %d", rate, requests)
+	}
+}
+
+func TestPrometheusSignal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("query") {
+		case "stable":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0.01"]}]}}`)
+		case "candidate":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"0.2"]}}`)
+		case "empty":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","error":"parse error"}`)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		stableQuery   string
+		wantStable    float64
+		wantCandidate float64
+		wantErr       error
+	}{
+		{"rates", "stable", 0.01, 0.2, nil},
+		{"no data", "empty", 0, 0, ErrNotEnoughData},
+		{"bad query", "invalid", 0, 0, errors.New("prometheus query failed: parse error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := NewPrometheusSignal(server.URL, tt.stableQuery, "candidate")
+			stable, candidate, err := signal.ErrorRates(context.Background())
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Errorf("expected error %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stable != tt.wantStable || candidate != tt.wantCandidate {
+				t.Errorf("expected %v and %v, got %v and %v", tt.wantStable, tt.wantCandidate, stable, candidate)
+			}
+		})
+	}
+}
+
+func TestRolloutHealthSignal(t *testing.T) {
+	type TestConfig struct {
+		Value string
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := New[TestConfig]()
+	cfg.Update(ctx, TestConfig{Value: "stable"})
+
+	counter := NewErrorRateCounter(time.Minute).WithMinRequests(20)
+	rollout := NewRollout(cfg).
+		WithStages(RolloutStage{Percentage: 50, Duration: time.Hour}).
+		WithRollbackThreshold(0.05).
+		WithHealthSignal(counter).
+		WithHealthCheckInterval(10 * time.Millisecond)
+	if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Both variants fail at the same rate, which is not the candidate's fault
+	for i := 0; i < 100; i++ {
+		_, variant := rollout.ResolveVariant(WithSubject(ctx, Subject{ID: fmt.Sprintf("user-%d", i)}))
+		var err error
+		if i%10 == 0 {
+			err = errors.New("upstream timeout")
+		}
+		counter.Record(variant, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status := rollout.Status(); status.State != RolloutInProgress {
+		t.Fatalf("expected rollout in progress, got %+v", status)
+	}
+
+	for i := 0; i < 20; i++ {
+		counter.Record(VariantCandidate, errors.New("nil pointer"))
+	}
+
+	select {
+	case <-rollout.Done():
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for rollback")
+	}
+	if status := rollout.Status(); status.State != RolloutRolledBack {
+		t.Errorf("expected rollback, got %+v", status)
+	}
+	if got := cfg.Get(ctx).Value; got != "stable" {
+		t.Errorf("expected stable value, got %q", got)
+	}
+}
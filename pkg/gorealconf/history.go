@@ -0,0 +1,38 @@
+package gorealconf
+
+import "time"
+
+// HistoryEntry is a previously applied configuration value
+type HistoryEntry[T any] struct {
+	Version   uint64    `json:"version"`
+	AppliedAt time.Time `json:"applied_at"`
+	Value     T         `json:"value"`
+}
+
+// History returns the retained values, oldest first. Nothing is retained
+// unless the Config was created with WithHistory.
+func (c *Config[T]) History() []HistoryEntry[T] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history := make([]HistoryEntry[T], len(c.history))
+	copy(history, c.history)
+	return history
+}
+
+// recordHistory appends an entry, dropping the oldest beyond historySize.
+// The caller must hold c.mu.
+func (c *Config[T]) recordHistory(version uint64, value T) {
+	if c.historySize <= 0 {
+		return
+	}
+
+	c.history = append(c.history, HistoryEntry[T]{
+		Version:   version,
+		AppliedAt: time.Now(),
+		Value:     value,
+	})
+	if len(c.history) > c.historySize {
+		c.history = append(c.history[:0:0], c.history[len(c.history)-c.historySize:]...)
+	}
+}
@@ -0,0 +1,98 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+)
+
+// Attribute keys of the log records a Config emits
+const (
+	LogKeyConfig  = "config"
+	LogKeySource  = "source"
+	LogKeyVersion = "version"
+)
+
+// discardHandler drops every record. It is the default handler, so a
+// Config logs nothing until WithLogger is used.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var noLogger = slog.New(discardHandler{})
+
+// WithLogger logs source connections, rejected payloads and updates,
+// rollbacks and dropped subscribers to logger. Records carry the config's
+// name, see WithName, and the version they relate to.
+func WithLogger[T any](logger *slog.Logger) Option[T] {
+	return func(c *Config[T]) {
+		if logger == nil {
+			logger = noLogger
+		}
+		c.logger = logger
+	}
+}
+
+type loggerKey struct{}
+
+// LoggerFromContext returns the logger a Config passes to its sources'
+// Load and Watch methods, labelled with the config and source names. It
+// never returns nil, so sources can log unconditionally.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return noLogger
+}
+
+func contextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// log returns the config's logger labelled with the source name
+func (c *Config[T]) log(source string) *slog.Logger {
+	return c.logger.With(LogKeySource, source)
+}
+
+// payloadAttr describes a payload that failed to decode without logging
+// any of its content, which may hold secrets: its size and, for JSON
+// syntax and type errors, the byte offset of the problem. Errors of the
+// YAML and TOML decoders name the line themselves.
+func payloadAttr(data []byte, err error) slog.Attr {
+	attrs := []any{"bytes", len(data)}
+
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		attrs = append(attrs, "offset", syntax.Offset)
+	case errors.As(err, &typ):
+		attrs = append(attrs, "offset", typ.Offset)
+	}
+	return slog.Group("payload", attrs...)
+}
+
+// ruleNames returns the names of the rules err reports as failed, see
+// RuleError
+func ruleNames(err error) []string {
+	var rerr *RuleError
+	if errors.As(err, &rerr) {
+		return rerr.Rules()
+	}
+	return nil
+}
+
+// withoutURL strips the request URL from an HTTP client error before it is
+// logged, since webhook URLs often embed a token
+func withoutURL(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
+	}
+	return err
+}
@@ -0,0 +1,213 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordHandler keeps every record with its attributes flattened
+type recordHandler struct {
+	mu      *sync.Mutex
+	records *[]map[string]any
+	attrs   []slog.Attr
+}
+
+func newRecordHandler() *recordHandler {
+	return &recordHandler{mu: new(sync.Mutex), records: new([]map[string]any)}
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := map[string]any{"msg": r.Message, "level": r.Level}
+	for _, a := range h.attrs {
+		rec[a.Key] = a.Value.Any()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		rec[a.Key] = a.Value.Any()
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.records = append(*h.records, rec)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &next
+}
+
+func (h *recordHandler) WithGroup(string) slog.Handler { return h }
+
+// find waits for a record with msg
+func (h *recordHandler) find(t *testing.T, msg string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		h.mu.Lock()
+		for _, rec := range *h.records {
+			if rec["msg"] == msg {
+				h.mu.Unlock()
+				return rec
+			}
+		}
+		h.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %q record logged", msg)
+	return nil
+}
+
+func TestLogging(t *testing.T) {
+	type TestConfig struct {
+		Value    string `json:"value"`
+		MaxConns int    `json:"max_conns"`
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("validation", func(t *testing.T) {
+		handler := newRecordHandler()
+		cfg := New[TestConfig](
+			WithName[TestConfig]("app"),
+			WithLogger[TestConfig](slog.New(handler)),
+			WithRollback[TestConfig](true),
+			WithValidationRules(
+				ValidationRule[TestConfig]{Name: "value_required", Check: func(_, new TestConfig) error {
+					if new.Value == "" {
+						return errors.New("value is required")
+					}
+					return nil
+				}},
+				ValidationRule[TestConfig]{Name: "max_conns_positive", Check: func(_, new TestConfig) error {
+					if new.MaxConns <= 0 {
+						return errors.New("max_conns must be positive")
+					}
+					return nil
+				}},
+			),
+		)
+
+		if err := cfg.Update(ctx, TestConfig{Value: "a", MaxConns: 1}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		updated := handler.find(t, "config updated")
+		if updated[LogKeyConfig] != "app" || updated[LogKeySource] != SourceManual || updated[LogKeyVersion] != uint64(1) {
+			t.Errorf("unexpected record %v", updated)
+		}
+
+		err := cfg.Update(ctx, TestConfig{})
+		var rerr *RuleError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("expected a *RuleError, got %v", err)
+		}
+
+		rejected := handler.find(t, "validation failed")
+		want := []string{"value_required", "max_conns_positive"}
+		if !reflect.DeepEqual(rejected["rules"], want) {
+			t.Errorf("expected rules %v, got %v", want, rejected["rules"])
+		}
+		if rejected["level"] != slog.LevelWarn || rejected[LogKeyVersion] != uint64(1) {
+			t.Errorf("unexpected record %v", rejected)
+		}
+		handler.find(t, "update rolled back")
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		handler := newRecordHandler()
+		source := &rawTestSource[TestConfig]{
+			data:    []byte(`{"value":"initial","max_conns":1}`),
+			changes: make(chan []byte, 1),
+		}
+		cfg := New[TestConfig](
+			WithLogger[TestConfig](slog.New(handler)),
+			WithNamedSource[TestConfig]("primary", source),
+		)
+		if err := cfg.Load(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		connected := handler.find(t, "source connected")
+		if connected[LogKeySource] != "primary" {
+			t.Errorf("unexpected record %v", connected)
+		}
+		if _, ok := connected[LogKeyConfig]; ok {
+			t.Error("expected no config name on an unnamed config")
+		}
+
+		source.changes <- []byte(`{"value":"hunter2","max_conns":"many"}`)
+		rejected := handler.find(t, "payload rejected")
+		if record := fmt.Sprint(rejected); strings.Contains(record, "hunter2") || strings.Contains(record, "many") {
+			t.Errorf("payload content logged: %s", record)
+		}
+		if payload := fmt.Sprint(rejected["payload"]); !strings.Contains(payload, "bytes=38") || !strings.Contains(payload, "offset=") {
+			t.Errorf("expected the payload size and error offset, got %s", payload)
+		}
+
+		close(source.changes)
+		disconnected := handler.find(t, "source disconnected")
+		if disconnected["level"] != slog.LevelWarn {
+			t.Errorf("unexpected record %v", disconnected)
+		}
+	})
+
+	t.Run("subscribers", func(t *testing.T) {
+		handler := newRecordHandler()
+		cfg := New[TestConfig](WithLogger[TestConfig](slog.New(handler)))
+
+		_, unsubscribe := cfg.Subscribe(ctx)
+		defer unsubscribe()
+
+		// The subscriber never reads, so the second update finds its
+		// buffer full
+		for _, value := range []string{"a", "b"} {
+			if err := cfg.Update(ctx, TestConfig{Value: value}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		dropped := handler.find(t, "slow subscribers dropped")
+		if dropped["dropped"] != int64(1) || dropped[LogKeyVersion] != uint64(2) {
+			t.Errorf("unexpected record %v", dropped)
+		}
+	})
+}
+
+func TestPayloadAttr(t *testing.T) {
+	secret := []byte("password: hunter2\n- hunter3\nkey: |\n  hunter4\n")
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+		want string
+	}{
+		{"json syntax", []byte(`{"password":"hunter2",}`), json.Unmarshal([]byte(`{"password":"hunter2",}`), new(any)), "payload=[bytes=23 offset=23]"},
+		{"json type", []byte(`{"port":"hunter2"}`), json.Unmarshal([]byte(`{"port":"hunter2"}`), new(struct{ Port int })), "payload=[bytes=18 offset=17]"},
+		{"other", secret, errors.New("yaml: line 2: did not find expected key"), "payload=[bytes=45]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := payloadAttr(tt.data, tt.err).String()
+			if strings.Contains(got, "hunter") {
+				t.Errorf("payload content logged: %s", got)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
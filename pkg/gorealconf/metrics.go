@@ -1,87 +1,342 @@
 // metrics.go
 package gorealconf
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsRecorder records configuration metrics. Metrics implements it
+// with Prometheus; the otelconf package implements it with OpenTelemetry.
+type MetricsRecorder interface {
+	IncConfigUpdates(source, valid string)
+	SetConfigVersion(version float64)
+	ObserveUpdateDuration(duration float64)
+	SetLastUpdate(source string, t time.Time)
+	IncLoadErrors(source string)
+	IncWatchErrors(source string)
+	IncWatchReconnects(source string)
+	IncUpdateErrors(source string)
+	IncDecodeErrors(source string)
+	IncValidationErrors(source string)
+	IncVerificationFailures()
+	IncRollbackCount()
+	SetSubscribers(count int)
+	ObserveSubscriberLag(lag float64)
+	SetRolloutStage(stage int, percentage float64)
+	IncVariantServed(variant string)
+	IncExperimentExposure(experiment, variant string)
+	IncFlagEvaluation(flag, variant string)
+}
+
+var _ MetricsRecorder = (*Metrics)(nil)
+
+// noMetrics records nothing; the methods of Metrics are nil-safe
+var noMetrics *Metrics
+
+// Metrics records configuration metrics with Prometheus. Create one per
+// Config with NewMetrics, or share a MetricsCollector between several.
 type Metrics struct {
-	configUpdates  *prometheus.CounterVec
-	configVersions prometheus.Gauge
-	updateDuration prometheus.Histogram
-	validationErrs prometheus.Counter
-	rollbackCount  prometheus.Counter
-	loadErrors     prometheus.Counter
-	watchErrors    prometheus.Counter
-	updateErrors   prometheus.Counter
+	configUpdates   *prometheus.CounterVec
+	configVersions  prometheus.Gauge
+	updateDuration  prometheus.Observer
+	validationErrs  *prometheus.CounterVec
+	rollbackCount   prometheus.Counter
+	loadErrors      *prometheus.CounterVec
+	watchErrors     *prometheus.CounterVec
+	updateErrors    *prometheus.CounterVec
+	decodeErrors    *prometheus.CounterVec
+	reconnects      *prometheus.CounterVec
+	lastUpdate      *prometheus.GaugeVec
+	staleness       *stalenessCollector
+	subscribers     prometheus.Gauge
+	subscriberLag   prometheus.Observer
+	verifyErrors    prometheus.Counter
+	rolloutStage    prometheus.Gauge
+	rolloutVariants *prometheus.GaugeVec
+	variantsServed  *prometheus.CounterVec
+	exposures       *prometheus.CounterVec
+	flagEvals       *prometheus.CounterVec
+
+	// labels are the values of the vecs' leading labels, such as the
+	// config name of a shared collector
+	labels []string
+	vecs   *metricVecs
 }
 
-func NewMetrics(name string) *Metrics {
-	return &Metrics{
+// metricVecs holds the collectors behind one or more Metrics. Every metric
+// is a vec whose leading labels identify the Metrics; for NewMetrics there
+// are none.
+type metricVecs struct {
+	labels          []string
+	configUpdates   *prometheus.CounterVec
+	configVersions  *prometheus.GaugeVec
+	updateDuration  *prometheus.HistogramVec
+	validationErrs  *prometheus.CounterVec
+	rollbackCount   *prometheus.CounterVec
+	loadErrors      *prometheus.CounterVec
+	watchErrors     *prometheus.CounterVec
+	updateErrors    *prometheus.CounterVec
+	decodeErrors    *prometheus.CounterVec
+	reconnects      *prometheus.CounterVec
+	lastUpdate      *prometheus.GaugeVec
+	staleness       *stalenessCollector
+	subscribers     *prometheus.GaugeVec
+	subscriberLag   *prometheus.HistogramVec
+	verifyErrors    *prometheus.CounterVec
+	rolloutStage    *prometheus.GaugeVec
+	rolloutVariants *prometheus.GaugeVec
+	variantsServed  *prometheus.CounterVec
+	exposures       *prometheus.CounterVec
+	flagEvals       *prometheus.CounterVec
+}
+
+func newMetricVecs(name string, labels ...string) *metricVecs {
+	with := func(extra ...string) []string {
+		return append(append([]string{}, labels...), extra...)
+	}
+
+	return &metricVecs{
+		labels: labels,
 		configUpdates: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: name + "_config_updates_total",
 				Help: "Total number of configuration updates",
 			},
-			[]string{"source", "valid"},
+			with("source", "valid"),
 		),
-		configVersions: prometheus.NewGauge(
+		configVersions: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: name + "_config_version",
 				Help: "Current configuration version",
 			},
+			with(),
 		),
-		updateDuration: prometheus.NewHistogram(
+		updateDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name: name + "_update_duration_seconds",
 				Help: "Duration of configuration updates",
 			},
+			with(),
 		),
-		validationErrs: prometheus.NewCounter(
+		validationErrs: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: name + "_validation_errors_total",
 				Help: "Total number of validation errors",
 			},
+			with("source"),
 		),
-		rollbackCount: prometheus.NewCounter(
+		rollbackCount: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: name + "_rollback_total",
 				Help: "Total number of configuration rollbacks",
 			},
+			with(),
 		),
-		loadErrors: prometheus.NewCounter(
+		loadErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: name + "_load_errors_total",
 				Help: "Total number of configuration loading errors",
 			},
+			with("source"),
 		),
-		watchErrors: prometheus.NewCounter(
+		watchErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: name + "_watch_errors_total",
 				Help: "Total number of configuration watch errors",
 			},
+			with("source"),
 		),
-		updateErrors: prometheus.NewCounter(
+		updateErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: name + "_update_errors_total",
 				Help: "Total number of configuration update errors",
 			},
+			with("source"),
+		),
+		decodeErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: name + "_decode_errors_total",
+				Help: "Total number of payloads that could not be decoded",
+			},
+			with("source"),
+		),
+		reconnects: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: name + "_watch_reconnects_total",
+				Help: "Total number of times a source watch was restarted",
+			},
+			with("source"),
+		),
+		lastUpdate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: name + "_last_update_timestamp_seconds",
+				Help: "Unix time of the last successful update per source",
+			},
+			with("source"),
+		),
+		staleness: newStalenessCollector(prometheus.NewDesc(
+			name+"_staleness_seconds",
+			"Seconds since the last successful update per source",
+			with("source"), nil,
+		)),
+		subscribers: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: name + "_subscribers",
+				Help: "Current number of subscribers",
+			},
+			with(),
+		),
+		subscriberLag: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    name + "_subscriber_lag_seconds",
+				Help:    "Delay between applying an update and notifying subscribers",
+				Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+			},
+			with(),
+		),
+		verifyErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: name + "_signature_verification_failures_total",
+				Help: "Total number of payloads rejected by signature verification",
+			},
+			with(),
 		),
+		rolloutStage: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: name + "_rollout_stage",
+				Help: "Current rollout stage, starting at 1, or 0 when no rollout is in progress",
+			},
+			with(),
+		),
+		rolloutVariants: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: name + "_rollout_variant_percentage",
+				Help: "Percentage of traffic served per rollout variant",
+			},
+			with("variant"),
+		),
+		variantsServed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: name + "_rollout_variant_served_total",
+				Help: "Total number of requests served per rollout variant",
+			},
+			with("variant"),
+		),
+		exposures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: name + "_experiment_exposures_total",
+				Help: "Total number of experiment exposures per variant",
+			},
+			with("experiment", "variant"),
+		),
+		flagEvals: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: name + "_flag_evaluations_total",
+				Help: "Total number of feature flag evaluations per variant",
+			},
+			with("flag", "variant"),
+		),
+	}
+}
+
+func (v *metricVecs) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		v.configUpdates,
+		v.configVersions,
+		v.updateDuration,
+		v.validationErrs,
+		v.rollbackCount,
+		v.loadErrors,
+		v.watchErrors,
+		v.updateErrors,
+		v.decodeErrors,
+		v.reconnects,
+		v.lastUpdate,
+		v.staleness,
+		v.subscribers,
+		v.subscriberLag,
+		v.verifyErrors,
+		v.rolloutStage,
+		v.rolloutVariants,
+		v.variantsServed,
+		v.exposures,
+		v.flagEvals,
+	}
+}
+
+// metrics returns the Metrics identified by values of the leading labels
+func (v *metricVecs) metrics(values ...string) *Metrics {
+	curry := prometheus.Labels{}
+	for i, label := range v.labels {
+		curry[label] = values[i]
+	}
+
+	return &Metrics{
+		configUpdates:   v.configUpdates.MustCurryWith(curry),
+		configVersions:  v.configVersions.WithLabelValues(values...),
+		updateDuration:  v.updateDuration.WithLabelValues(values...),
+		validationErrs:  v.validationErrs.MustCurryWith(curry),
+		rollbackCount:   v.rollbackCount.WithLabelValues(values...),
+		loadErrors:      v.loadErrors.MustCurryWith(curry),
+		watchErrors:     v.watchErrors.MustCurryWith(curry),
+		updateErrors:    v.updateErrors.MustCurryWith(curry),
+		decodeErrors:    v.decodeErrors.MustCurryWith(curry),
+		reconnects:      v.reconnects.MustCurryWith(curry),
+		lastUpdate:      v.lastUpdate.MustCurryWith(curry),
+		staleness:       v.staleness,
+		subscribers:     v.subscribers.WithLabelValues(values...),
+		subscriberLag:   v.subscriberLag.WithLabelValues(values...),
+		verifyErrors:    v.verifyErrors.WithLabelValues(values...),
+		rolloutStage:    v.rolloutStage.WithLabelValues(values...),
+		rolloutVariants: v.rolloutVariants.MustCurryWith(curry),
+		variantsServed:  v.variantsServed.MustCurryWith(curry),
+		exposures:       v.exposures.MustCurryWith(curry),
+		flagEvals:       v.flagEvals.MustCurryWith(curry),
+		labels:          values,
+	}
+}
+
+// delete removes every series of the Metrics identified by values
+func (v *metricVecs) delete(values ...string) {
+	match := prometheus.Labels{}
+	for i, label := range v.labels {
+		match[label] = values[i]
+	}
+
+	vecs := []interface{ DeletePartialMatch(prometheus.Labels) int }{
+		v.configUpdates, v.configVersions, v.updateDuration, v.validationErrs,
+		v.rollbackCount, v.loadErrors, v.watchErrors, v.updateErrors,
+		v.decodeErrors, v.reconnects, v.lastUpdate, v.subscribers,
+		v.subscriberLag, v.verifyErrors, v.rolloutStage, v.rolloutVariants,
+		v.variantsServed, v.exposures, v.flagEvals,
+	}
+	for _, vec := range vecs {
+		vec.DeletePartialMatch(match)
 	}
+	v.staleness.delete(values...)
 }
 
-// Register registers all metrics with the provided registry
+func NewMetrics(name string) *Metrics {
+	vecs := newMetricVecs(name)
+	m := vecs.metrics()
+	m.vecs = vecs
+	return m
+}
+
+// Register registers all metrics with the provided registry. Metrics of a
+// MetricsCollector are registered with the collector instead.
 func (m *Metrics) Register(reg prometheus.Registerer) error {
-	metrics := []prometheus.Collector{
-		m.configUpdates,
-		m.configVersions,
-		m.updateDuration,
-		m.validationErrs,
-		m.rollbackCount,
-		m.loadErrors,
-		m.watchErrors,
-		m.updateErrors,
-	}
-
-	for _, metric := range metrics {
+	if m.vecs == nil {
+		return errors.New("metrics belong to a MetricsCollector, register the collector")
+	}
+
+	for _, metric := range m.vecs.collectors() {
 		if err := reg.Register(metric); err != nil {
 			return err
 		}
@@ -90,10 +345,65 @@ func (m *Metrics) Register(reg prometheus.Registerer) error {
 	return nil
 }
 
+// stalenessCollector reports the age of each source's last update at
+// scrape time
+type stalenessCollector struct {
+	desc *prometheus.Desc
+	now  func() time.Time
+
+	mu      sync.Mutex
+	updated map[string]stalenessEntry
+}
+
+type stalenessEntry struct {
+	labels []string
+	at     time.Time
+}
+
+func newStalenessCollector(desc *prometheus.Desc) *stalenessCollector {
+	return &stalenessCollector{
+		desc:    desc,
+		now:     time.Now,
+		updated: make(map[string]stalenessEntry),
+	}
+}
+
+func (s *stalenessCollector) set(t time.Time, labels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updated[strings.Join(labels, "\xff")] = stalenessEntry{labels: labels, at: t}
+}
+
+// delete removes the entries whose leading labels are prefix
+func (s *stalenessCollector) delete(prefix ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, entry := range s.updated {
+		if len(entry.labels) >= len(prefix) && slices.Equal(entry.labels[:len(prefix)], prefix) {
+			delete(s.updated, key)
+		}
+	}
+}
+
+func (s *stalenessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+func (s *stalenessCollector) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, entry := range s.updated {
+		ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, now.Sub(entry.at).Seconds(), entry.labels...)
+	}
+}
+
 // Helper methods for the Metrics type
-func (m *Metrics) IncValidationErrors() {
+func (m *Metrics) IncValidationErrors(source string) {
 	if m != nil && m.validationErrs != nil {
-		m.validationErrs.Inc()
+		m.validationErrs.WithLabelValues(source).Inc()
 	}
 }
 
@@ -103,21 +413,33 @@ func (m *Metrics) IncRollbackCount() {
 	}
 }
 
-func (m *Metrics) IncLoadErrors() {
+func (m *Metrics) IncLoadErrors(source string) {
 	if m != nil && m.loadErrors != nil {
-		m.loadErrors.Inc()
+		m.loadErrors.WithLabelValues(source).Inc()
 	}
 }
 
-func (m *Metrics) IncWatchErrors() {
+func (m *Metrics) IncWatchErrors(source string) {
 	if m != nil && m.watchErrors != nil {
-		m.watchErrors.Inc()
+		m.watchErrors.WithLabelValues(source).Inc()
 	}
 }
 
-func (m *Metrics) IncUpdateErrors() {
+func (m *Metrics) IncUpdateErrors(source string) {
 	if m != nil && m.updateErrors != nil {
-		m.updateErrors.Inc()
+		m.updateErrors.WithLabelValues(source).Inc()
+	}
+}
+
+func (m *Metrics) IncDecodeErrors(source string) {
+	if m != nil && m.decodeErrors != nil {
+		m.decodeErrors.WithLabelValues(source).Inc()
+	}
+}
+
+func (m *Metrics) IncWatchReconnects(source string) {
+	if m != nil && m.reconnects != nil {
+		m.reconnects.WithLabelValues(source).Inc()
 	}
 }
 
@@ -138,3 +460,68 @@ func (m *Metrics) IncConfigUpdates(source, valid string) {
 		m.configUpdates.WithLabelValues(source, valid).Inc()
 	}
 }
+
+// SetLastUpdate records a successful update from source at t
+func (m *Metrics) SetLastUpdate(source string, t time.Time) {
+	if m == nil {
+		return
+	}
+	if m.lastUpdate != nil {
+		m.lastUpdate.WithLabelValues(source).Set(float64(t.UnixNano()) / 1e9)
+	}
+	if m.staleness != nil {
+		m.staleness.set(t, append(slices.Clone(m.labels), source)...)
+	}
+}
+
+func (m *Metrics) SetSubscribers(count int) {
+	if m != nil && m.subscribers != nil {
+		m.subscribers.Set(float64(count))
+	}
+}
+
+func (m *Metrics) ObserveSubscriberLag(lag float64) {
+	if m != nil && m.subscriberLag != nil {
+		m.subscriberLag.Observe(lag)
+	}
+}
+
+func (m *Metrics) IncVerificationFailures() {
+	if m != nil && m.verifyErrors != nil {
+		m.verifyErrors.Inc()
+	}
+}
+
+// SetRolloutStage records the current stage index and the share of
+// traffic it serves the candidate. A negative stage means no rollout is in
+// progress.
+func (m *Metrics) SetRolloutStage(stage int, percentage float64) {
+	if m == nil {
+		return
+	}
+	if m.rolloutStage != nil {
+		m.rolloutStage.Set(float64(stage + 1))
+	}
+	if m.rolloutVariants != nil {
+		m.rolloutVariants.WithLabelValues(VariantCandidate).Set(percentage)
+		m.rolloutVariants.WithLabelValues(VariantStable).Set(100 - percentage)
+	}
+}
+
+func (m *Metrics) IncVariantServed(variant string) {
+	if m != nil && m.variantsServed != nil {
+		m.variantsServed.WithLabelValues(variant).Inc()
+	}
+}
+
+func (m *Metrics) IncExperimentExposure(experiment, variant string) {
+	if m != nil && m.exposures != nil {
+		m.exposures.WithLabelValues(experiment, variant).Inc()
+	}
+}
+
+func (m *Metrics) IncFlagEvaluation(flag, variant string) {
+	if m != nil && m.flagEvals != nil {
+		m.flagEvals.WithLabelValues(flag, variant).Inc()
+	}
+}
@@ -0,0 +1,159 @@
+package gorealconf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsCollector exports the metrics of several Configs from one set of
+// collectors, labelled by config name. Register it once, directly or
+// through prometheus.WrapRegistererWith, and pass it to each Config with
+// WithMetricsCollector.
+type MetricsCollector struct {
+	vecs *metricVecs
+
+	mu      sync.Mutex
+	configs map[string]*metricsClaim
+}
+
+// metricsClaim holds a config name for one Config across its Loads
+type metricsClaim struct {
+	owner any
+	loads int
+}
+
+var _ prometheus.Collector = (*MetricsCollector)(nil)
+
+func NewMetricsCollector(name string) *MetricsCollector {
+	return &MetricsCollector{
+		vecs:    newMetricVecs(name, "config"),
+		configs: make(map[string]*metricsClaim),
+	}
+}
+
+func (mc *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range mc.vecs.collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (mc *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range mc.vecs.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Metrics returns the metrics of the named config
+func (mc *MetricsCollector) Metrics(config string) *Metrics {
+	return mc.vecs.metrics(config)
+}
+
+// Configs returns the names of the registered configs
+func (mc *MetricsCollector) Configs() []string {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	names := make([]string, 0, len(mc.configs))
+	for name := range mc.configs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// register claims config for owner, a single Config, reporting whether
+// the name was free. An owner can claim its name again; the name is
+// released once every claim is.
+func (mc *MetricsCollector) register(config string, owner any) (bool, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	claim := mc.configs[config]
+	switch {
+	case claim == nil:
+		mc.configs[config] = &metricsClaim{owner: owner, loads: 1}
+		return true, nil
+	case claim.owner == owner:
+		claim.loads++
+		return false, nil
+	default:
+		return false, fmt.Errorf("config %q is already registered with the metrics collector", config)
+	}
+}
+
+// release gives up a claim made by register
+func (mc *MetricsCollector) release(config string, owner any) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	claim := mc.configs[config]
+	if claim == nil || claim.owner != owner {
+		return
+	}
+	if claim.loads--; claim.loads == 0 {
+		delete(mc.configs, config)
+		mc.vecs.delete(config)
+	}
+}
+
+// Unregister removes every series of the named config and releases the
+// name. Configs unregister themselves when their Load context is done.
+func (mc *MetricsCollector) Unregister(config string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	delete(mc.configs, config)
+	mc.vecs.delete(config)
+}
+
+// WithName names the config. The name labels its series in a
+// MetricsCollector.
+func WithName[T any](name string) Option[T] {
+	return func(c *Config[T]) {
+		c.name = name
+	}
+}
+
+// WithMetricsCollector records the config's metrics in collector under the
+// config's name, see WithName. The config registers itself on Load and
+// unregisters when the Load context is done, or when Load fails.
+func WithMetricsCollector[T any](collector *MetricsCollector) Option[T] {
+	return func(c *Config[T]) {
+		c.collector = collector
+	}
+}
+
+// Name returns the config's name, see WithName
+func (c *Config[T]) Name() string {
+	return c.name
+}
+
+// registerMetrics claims the config's name in its metrics collector until
+// ctx is done. The returned function releases the claim early, for a Load
+// that fails.
+func (c *Config[T]) registerMetrics(ctx context.Context) (release func(), err error) {
+	if c.collector == nil {
+		return func() {}, nil
+	}
+	first, err := c.collector.register(c.name, c)
+	if err != nil {
+		return nil, err
+	}
+	if first {
+		// Series of a previous config with the same name may have been
+		// removed
+		c.metrics = c.collector.Metrics(c.name)
+	}
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() { c.collector.release(c.name, c) })
+	}
+	go func() {
+		<-ctx.Done()
+		release()
+	}()
+	return release, nil
+}
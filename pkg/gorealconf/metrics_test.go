@@ -0,0 +1,246 @@
+package gorealconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSourceMetrics(t *testing.T) {
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	metrics := NewMetrics("test")
+	reg := prometheus.NewRegistry()
+	if err := metrics.Register(reg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source := &rawTestSource[TestConfig]{
+		data:    []byte(`{"value":"initial"}`),
+		changes: make(chan []byte, 1),
+	}
+	cfg := New[TestConfig](
+		WithMetrics[TestConfig](metrics),
+		WithNamedSource[TestConfig]("primary", source),
+	)
+	if err := cfg.Load(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	t.Run("updates", func(t *testing.T) {
+		source.changes <- []byte(`{not json`)
+		waitFor("decode error", func() bool {
+			return testutil.ToFloat64(metrics.decodeErrors.WithLabelValues("primary")) == 1
+		})
+
+		source.changes <- []byte(`{"value":"changed"}`)
+		waitFor("update", func() bool {
+			return testutil.ToFloat64(metrics.configUpdates.WithLabelValues("primary", "true")) == 2
+		})
+
+		if err := cfg.Update(ctx, TestConfig{Value: "manual"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := testutil.ToFloat64(metrics.configUpdates.WithLabelValues(SourceManual, "true")); got != 1 {
+			t.Errorf("expected 1 manual update, got %v", got)
+		}
+		if got := testutil.ToFloat64(metrics.lastUpdate.WithLabelValues("primary")); got <= 0 {
+			t.Errorf("expected last update timestamp, got %v", got)
+		}
+	})
+
+	t.Run("staleness", func(t *testing.T) {
+		metrics.staleness.now = func() time.Time { return time.Now().Add(time.Minute) }
+		defer func() { metrics.staleness.now = time.Now }()
+
+		if got := testutil.CollectAndCount(metrics.staleness); got != 2 {
+			t.Fatalf("expected staleness for 2 sources, got %d", got)
+		}
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, family := range families {
+			if family.GetName() != "test_staleness_seconds" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				if v := m.GetGauge().GetValue(); v < 59 || v > 61 {
+					t.Errorf("expected about 60s of staleness, got %v", v)
+				}
+			}
+		}
+	})
+
+	t.Run("subscribers", func(t *testing.T) {
+		_, unsubscribe := cfg.Subscribe(ctx)
+		if got := testutil.ToFloat64(metrics.subscribers); got != 1 {
+			t.Errorf("expected 1 subscriber, got %v", got)
+		}
+		unsubscribe()
+		if got := testutil.ToFloat64(metrics.subscribers); got != 0 {
+			t.Errorf("expected no subscribers, got %v", got)
+		}
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		close(source.changes)
+		waitFor("reconnect", func() bool {
+			return testutil.ToFloat64(metrics.reconnects.WithLabelValues("primary")) >= 1
+		})
+	})
+}
+
+func TestRolloutMetrics(t *testing.T) {
+	type TestConfig struct {
+		Value string
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	metrics := NewMetrics("test")
+	cfg := New[TestConfig](WithMetrics[TestConfig](metrics))
+	cfg.Update(ctx, TestConfig{Value: "stable"})
+
+	rollout := NewRollout(cfg).WithStages(
+		RolloutStage{Percentage: 10, Duration: time.Hour},
+		RolloutStage{Percentage: 100, Duration: time.Hour},
+	)
+	if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(metrics.rolloutStage) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the first stage")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(metrics.rolloutVariants.WithLabelValues(VariantCandidate)); got != 10 {
+		t.Errorf("expected 10%% candidate traffic, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.rolloutVariants.WithLabelValues(VariantStable)); got != 90 {
+		t.Errorf("expected 90%% stable traffic, got %v", got)
+	}
+
+	if err := rollout.Abort("test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-rollout.Done()
+	if got := testutil.ToFloat64(metrics.rolloutStage); got != 0 {
+		t.Errorf("expected no stage after abort, got %v", got)
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	type TestConfig struct {
+		Value string
+	}
+
+	collector := NewMetricsCollector("app")
+	reg := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(prometheus.Labels{"service": "api"}, reg).MustRegister(collector)
+
+	paymentsCtx, cancelPayments := context.WithCancel(context.Background())
+	defer cancelPayments()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	load := func(ctx context.Context, name string) (*Config[TestConfig], error) {
+		cfg := New[TestConfig](WithName[TestConfig](name), WithMetricsCollector[TestConfig](collector))
+		if err := cfg.Load(ctx); err != nil {
+			return nil, err
+		}
+		return cfg, cfg.Update(ctx, TestConfig{Value: name})
+	}
+
+	if _, err := load(paymentsCtx, "payments"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := load(ctx, "search"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := load(ctx, "payments"); err == nil {
+		t.Error("expected an error for a duplicate config name")
+	}
+
+	versions := func() map[string]string {
+		t.Helper()
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		series := make(map[string]string)
+		for _, family := range families {
+			if family.GetName() != "app_config_version" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, pair := range m.GetLabel() {
+					labels[pair.GetName()] = pair.GetValue()
+				}
+				series[labels["config"]] = labels["service"]
+			}
+		}
+		return series
+	}
+
+	if got := versions(); len(got) != 2 || got["payments"] != "api" || got["search"] != "api" {
+		t.Fatalf("expected labelled series for both configs, got %v", got)
+	}
+
+	cancelPayments()
+	deadline := time.Now().Add(time.Second)
+	for len(collector.Configs()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for payments to unregister")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := versions(); len(got) != 1 || got["search"] == "" {
+		t.Errorf("expected only search series, got %v", got)
+	}
+
+	// The name can be reused once released
+	payments, err := load(ctx, "payments")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// and loaded again by the config holding it
+	if err := payments.Load(ctx); err != nil {
+		t.Errorf("unexpected error loading a config twice: %v", err)
+	}
+
+	// A failed Load releases the name at once
+	source := &rawTestSource[TestConfig]{data: []byte("{")}
+	orders := New[TestConfig](WithName[TestConfig]("orders"), WithMetricsCollector[TestConfig](collector), WithSource[TestConfig](source))
+	if err := orders.Load(ctx); err == nil {
+		t.Fatal("expected an error for an invalid payload")
+	}
+	source.data = []byte(`{"Value":"orders"}`)
+	if err := orders.Load(ctx); err != nil {
+		t.Errorf("expected a retry with the same context to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,479 @@
+package gorealconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Severity ranks how significant a configuration change is
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityWarning:
+		return 1
+	case SeverityCritical:
+		return 2
+	}
+	return 0
+}
+
+// Notification is an applied change that passed the notifier's filters.
+// Changes are computed from redacted values.
+type Notification struct {
+	Time     time.Time `json:"time"`
+	Config   string    `json:"config,omitempty"`
+	Source   string    `json:"source"`
+	Version  uint64    `json:"version"`
+	Severity Severity  `json:"severity"`
+	Changes  []Change  `json:"changes"`
+}
+
+// NotificationBatch is what a notifier posts at once: every notification
+// collected during the batch window, oldest version first. Severity is the
+// highest of the notifications.
+type NotificationBatch struct {
+	Config        string         `json:"config,omitempty"`
+	Severity      Severity       `json:"severity"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// NotificationEndpoint delivers rendered batches, for example to a chat
+// service. Returning a *NotificationError with a 4xx status other than 429
+// stops retries.
+type NotificationEndpoint interface {
+	Send(ctx context.Context, batch NotificationBatch, text string) error
+}
+
+// NotificationError is returned for a non-2xx response from an endpoint
+type NotificationError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *NotificationError) Error() string {
+	return fmt.Sprintf("notification endpoint returned %s", e.Status)
+}
+
+func (e *NotificationError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// DefaultNotificationTemplate renders a batch as plain text
+const DefaultNotificationTemplate = `[{{.Severity}}] {{with .Config}}{{.}}{{else}}configuration{{end}} changed
+{{- range .Notifications}}
+version {{.Version}} from {{.Source}}:
+{{- range .Changes}}
+  {{.Path}}: {{json .Old}} -> {{json .New}}
+{{- end}}
+{{- end}}`
+
+var notificationFuncs = template.FuncMap{
+	"json": func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	},
+}
+
+type severityRule struct {
+	pattern  string
+	severity Severity
+}
+
+type notifierOptions struct {
+	endpoints   []NotificationEndpoint
+	client      *http.Client
+	paths       []string
+	severities  []severityRule
+	minSeverity Severity
+	template    string
+	window      time.Duration
+	maxBatch    int
+	attempts    int
+	backoff     time.Duration
+}
+
+// NotifierOption configures a Notifier
+type NotifierOption func(*notifierOptions)
+
+// WithWebhook posts each batch to url as JSON: the batch's fields plus the
+// rendered template as "text"
+func WithWebhook(url string) NotifierOption {
+	return func(o *notifierOptions) {
+		o.endpoints = append(o.endpoints, &webhookEndpoint{url: url})
+	}
+}
+
+// WithSlackWebhook posts the rendered template to a Slack-compatible
+// incoming webhook as {"text": ...}
+func WithSlackWebhook(url string) NotifierOption {
+	return func(o *notifierOptions) {
+		o.endpoints = append(o.endpoints, &webhookEndpoint{url: url, textOnly: true})
+	}
+}
+
+// WithNotificationEndpoint delivers batches to endpoint
+func WithNotificationEndpoint(endpoint NotificationEndpoint) NotifierOption {
+	return func(o *notifierOptions) {
+		o.endpoints = append(o.endpoints, endpoint)
+	}
+}
+
+// WithNotifierHTTPClient posts webhooks with client instead of a client
+// with a 10 second timeout
+func WithNotifierHTTPClient(client *http.Client) NotifierOption {
+	return func(o *notifierOptions) {
+		o.client = client
+	}
+}
+
+// WithPathFilter only notifies about fields matching one of patterns. A
+// pattern is a dotted JSON field path whose segments may use the * and ?
+// wildcards, and matches the fields below it: "database" matches
+// "database.hosts[0]", and "*.timeout" matches "http.timeout".
+func WithPathFilter(patterns ...string) NotifierOption {
+	return func(o *notifierOptions) {
+		o.paths = append(o.paths, patterns...)
+	}
+}
+
+// WithSeverity assigns severity to changed fields matching pattern, see
+// WithPathFilter for the syntax. Other fields are SeverityInfo, and a
+// notification has the highest severity of its changes.
+func WithSeverity(pattern string, severity Severity) NotifierOption {
+	return func(o *notifierOptions) {
+		o.severities = append(o.severities, severityRule{pattern: pattern, severity: severity})
+	}
+}
+
+// WithMinSeverity drops notifications below severity
+func WithMinSeverity(severity Severity) NotifierOption {
+	return func(o *notifierOptions) {
+		o.minSeverity = severity
+	}
+}
+
+// WithNotificationTemplate renders batches with a text/template instead of
+// DefaultNotificationTemplate. The template receives a NotificationBatch
+// and may use the json function to format values.
+func WithNotificationTemplate(text string) NotifierOption {
+	return func(o *notifierOptions) {
+		o.template = text
+	}
+}
+
+// WithBatching collects notifications for window before posting them
+// together, or until max have been collected when max is positive
+func WithBatching(window time.Duration, max int) NotifierOption {
+	return func(o *notifierOptions) {
+		o.window = window
+		o.maxBatch = max
+	}
+}
+
+// WithNotificationRetry makes up to attempts deliveries per endpoint,
+// waiting backoff after the first failure and doubling it after each
+func WithNotificationRetry(attempts int, backoff time.Duration) NotifierOption {
+	return func(o *notifierOptions) {
+		o.attempts = attempts
+		o.backoff = backoff
+	}
+}
+
+// Notifier posts significant changes of a Config to webhooks and chat
+// services. Changes pass through the path and severity filters, are
+// batched, rendered with a template and delivered with retries.
+type Notifier[T any] struct {
+	config  *Config[T]
+	options notifierOptions
+	tmpl    *template.Template
+	queue   chan Notification
+	logger  *slog.Logger
+	start   sync.Once
+}
+
+// NewNotifier creates a notifier for config. It delivers nothing until
+// Start is called.
+func NewNotifier[T any](config *Config[T], opts ...NotifierOption) (*Notifier[T], error) {
+	options := notifierOptions{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		template: DefaultNotificationTemplate,
+		attempts: 3,
+		backoff:  time.Second,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if len(options.endpoints) == 0 {
+		return nil, errors.New("notifier has no endpoints")
+	}
+	for _, endpoint := range options.endpoints {
+		if e, ok := endpoint.(*webhookEndpoint); ok {
+			e.client = options.client
+		}
+	}
+
+	tmpl, err := template.New("notification").Funcs(notificationFuncs).Parse(options.template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+
+	return &Notifier[T]{
+		config:  config,
+		options: options,
+		tmpl:    tmpl,
+		queue:   make(chan Notification, 64),
+		logger:  config.logger.With("component", "notifier"),
+	}, nil
+}
+
+// Start subscribes to the config and delivers notifications until ctx is
+// done. Pending notifications are delivered before it stops, and the
+// subscription is removed. Calling Start again has no effect.
+func (n *Notifier[T]) Start(ctx context.Context) {
+	n.start.Do(func() {
+		remove := n.config.onChange(func(_ context.Context, event ChangeEvent[T]) {
+			if ctx.Err() != nil {
+				return
+			}
+			note, ok := n.notification(event)
+			if !ok {
+				return
+			}
+			select {
+			case n.queue <- note:
+			default:
+				n.logger.Warn("notification dropped", LogKeyVersion, event.Version, "reason", "queue full")
+			}
+		})
+
+		go func() {
+			defer remove()
+			n.run(ctx)
+		}()
+	})
+}
+
+// notification builds the notification for event, reporting false when
+// the filters leave nothing to notify about
+func (n *Notifier[T]) notification(event ChangeEvent[T]) (Notification, bool) {
+	changes, err := Diff(event.previous, event.raw)
+	if err != nil {
+		n.logger.Error("notification diff failed", LogKeyVersion, event.Version, "error", err)
+		return Notification{}, false
+	}
+
+	note := Notification{
+		Time:     time.Now().UTC(),
+		Config:   n.config.name,
+		Source:   event.Source,
+		Version:  event.Version,
+		Severity: SeverityInfo,
+	}
+	for _, change := range changes {
+		if len(n.options.paths) > 0 && !matchAny(n.options.paths, change.Path) {
+			continue
+		}
+		note.Changes = append(note.Changes, change)
+		for _, rule := range n.options.severities {
+			if matchPath(rule.pattern, change.Path) && rule.severity.rank() > note.Severity.rank() {
+				note.Severity = rule.severity
+			}
+		}
+	}
+
+	if len(note.Changes) == 0 || note.Severity.rank() < n.options.minSeverity.rank() {
+		return Notification{}, false
+	}
+	return note, true
+}
+
+func (n *Notifier[T]) run(ctx context.Context) {
+	var (
+		pending []Notification
+		flush   <-chan time.Time
+	)
+	send := func(ctx context.Context) {
+		n.deliver(ctx, pending)
+		pending, flush = nil, nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if len(pending) > 0 {
+				send(context.WithoutCancel(ctx))
+			}
+			return
+		case note := <-n.queue:
+			pending = append(pending, note)
+			switch {
+			case n.options.window <= 0, n.options.maxBatch > 0 && len(pending) >= n.options.maxBatch:
+				send(ctx)
+			case flush == nil:
+				flush = time.After(n.options.window)
+			}
+		case <-flush:
+			send(ctx)
+		}
+	}
+}
+
+// deliver renders notifications as one batch and sends it to every
+// endpoint
+func (n *Notifier[T]) deliver(ctx context.Context, notifications []Notification) {
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].Version < notifications[j].Version
+	})
+
+	batch := NotificationBatch{
+		Config:        n.config.name,
+		Severity:      SeverityInfo,
+		Notifications: notifications,
+	}
+	for _, note := range notifications {
+		if note.Severity.rank() > batch.Severity.rank() {
+			batch.Severity = note.Severity
+		}
+	}
+	logger := n.logger.With(LogKeyVersion, notifications[len(notifications)-1].Version)
+
+	var text strings.Builder
+	if err := n.tmpl.Execute(&text, batch); err != nil {
+		logger.Error("notification template failed", "error", err)
+		return
+	}
+
+	for i, endpoint := range n.options.endpoints {
+		if err := n.send(ctx, endpoint, batch, text.String()); err != nil {
+			logger.Error("notification failed", "endpoint", endpointName(endpoint, i), "error", withoutURL(err))
+		}
+	}
+}
+
+// send delivers to a single endpoint, retrying with exponential backoff
+func (n *Notifier[T]) send(ctx context.Context, endpoint NotificationEndpoint, batch NotificationBatch, text string) error {
+	backoff := n.options.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = endpoint.Send(ctx, batch, text); err == nil {
+			return nil
+		}
+
+		var nerr *NotificationError
+		if errors.As(err, &nerr) && !nerr.retryable() {
+			return err
+		}
+		if attempt >= n.options.attempts || !sleepContext(ctx, backoff) {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// matchAny reports whether any of patterns matches p
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if matchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	indexSuffix    = regexp.MustCompile(`(\[\d+\])+$`)
+	escapeBrackets = strings.NewReplacer("[", `\[`, "]", `\]`)
+)
+
+// matchPath reports whether pattern matches the field path p or one of its
+// parents, see WithPathFilter
+func matchPath(pattern, p string) bool {
+	want := strings.Split(pattern, ".")
+	got := strings.Split(p, ".")
+	if len(want) > len(got) {
+		return false
+	}
+	for i, segment := range want {
+		// Brackets are array indexes, not character classes
+		segment = escapeBrackets.Replace(segment)
+		if ok, _ := path.Match(segment, got[i]); ok {
+			continue
+		}
+		if ok, _ := path.Match(segment, indexSuffix.ReplaceAllString(got[i], "")); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookEndpoint posts batches to an HTTP endpoint
+type webhookEndpoint struct {
+	url      string
+	textOnly bool
+	client   *http.Client
+}
+
+func (e *webhookEndpoint) Send(ctx context.Context, batch NotificationBatch, text string) error {
+	var body any = struct {
+		NotificationBatch
+		Text string `json:"text"`
+	}{batch, text}
+	if e.textOnly {
+		body = map[string]string{"text": text}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &NotificationError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// endpointName identifies an endpoint in logs without exposing webhook
+// URLs, which often embed credentials
+func endpointName(endpoint NotificationEndpoint, i int) string {
+	if e, ok := endpoint.(*webhookEndpoint); ok {
+		if u, err := url.Parse(e.url); err == nil {
+			return u.Host
+		}
+	}
+	return fmt.Sprintf("endpoint-%d", i)
+}
@@ -0,0 +1,314 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// notificationServer records the JSON bodies posted to it, failing the
+// first failures requests
+type notificationServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	bodies   []map[string]any
+	failures int
+	status   int
+}
+
+func newNotificationServer(t *testing.T, failures, status int) *notificationServer {
+	s := &notificationServer{failures: failures, status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(s.status)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.bodies = append(s.bodies, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// wait returns the bodies once n have been received
+func (s *notificationServer) wait(t *testing.T, n int) []map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		if len(s.bodies) >= n {
+			bodies := append([]map[string]any(nil), s.bodies...)
+			s.mu.Unlock()
+			return bodies
+		}
+		s.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d notifications", n)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	type Database struct {
+		Hosts    []string `json:"hosts"`
+		Password string   `json:"password" secret:"true"`
+	}
+	type TestConfig struct {
+		Value    string   `json:"value"`
+		Database Database `json:"database"`
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("webhooks", func(t *testing.T) {
+		webhook := newNotificationServer(t, 0, 0)
+		slack := newNotificationServer(t, 0, 0)
+
+		cfg := New[TestConfig](WithName[TestConfig]("app"))
+		notifier, err := NewNotifier(cfg,
+			WithWebhook(webhook.URL),
+			WithSlackWebhook(slack.URL),
+			WithSeverity("database", SeverityCritical),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		notifier.Start(ctx)
+
+		value := TestConfig{Value: "a", Database: Database{Hosts: []string{"db1"}, Password: "hunter2"}}
+		if err := cfg.Update(ctx, value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body := webhook.wait(t, 1)[0]
+		if body["config"] != "app" || body["severity"] != string(SeverityCritical) {
+			t.Errorf("unexpected body %v", body)
+		}
+		notes, _ := body["notifications"].([]any)
+		if len(notes) != 1 {
+			t.Fatalf("expected one notification, got %v", body["notifications"])
+		}
+
+		text, _ := slack.wait(t, 1)[0]["text"].(string)
+		if !strings.HasPrefix(text, "[critical] app changed") || !strings.Contains(text, `database.hosts: null -> ["db1"]`) {
+			t.Errorf("unexpected text %q", text)
+		}
+		if strings.Contains(text, "hunter2") {
+			t.Errorf("secret leaked: %q", text)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		server := newNotificationServer(t, 0, 0)
+
+		cfg := New[TestConfig]()
+		notifier, err := NewNotifier(cfg,
+			WithWebhook(server.URL),
+			WithPathFilter("database.*"),
+			WithSeverity("database.hosts", SeverityWarning),
+			WithMinSeverity(SeverityWarning),
+			WithNotificationTemplate(`{{range .Notifications}}{{range .Changes}}{{.Path}} {{end}}{{end}}`),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		notifier.Start(ctx)
+
+		// Only value changes, which the path filter drops
+		if err := cfg.Update(ctx, TestConfig{Value: "a"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		if err := cfg.Update(ctx, TestConfig{Value: "b", Database: Database{Hosts: []string{"db1"}}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := cfg.Update(ctx, TestConfig{Value: "b", Database: Database{Hosts: []string{"db2"}}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		bodies := server.wait(t, 2)
+		time.Sleep(50 * time.Millisecond)
+		if bodies = server.wait(t, 2); len(bodies) != 2 {
+			t.Fatalf("expected 2 notifications, got %v", bodies)
+		}
+		texts := []any{bodies[0]["text"], bodies[1]["text"]}
+		if !reflect.DeepEqual(texts, []any{"database.hosts ", "database.hosts[0] "}) &&
+			!reflect.DeepEqual(texts, []any{"database.hosts[0] ", "database.hosts "}) {
+			t.Errorf("unexpected texts %q", texts)
+		}
+	})
+
+	t.Run("batching", func(t *testing.T) {
+		server := newNotificationServer(t, 0, 0)
+
+		cfg := New[TestConfig]()
+		notifier, err := NewNotifier(cfg,
+			WithWebhook(server.URL),
+			WithBatching(100*time.Millisecond, 0),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		notifier.Start(ctx)
+
+		for _, value := range []string{"a", "b", "c"} {
+			if err := cfg.Update(ctx, TestConfig{Value: value}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		body := server.wait(t, 1)[0]
+		var versions []float64
+		for _, note := range body["notifications"].([]any) {
+			versions = append(versions, note.(map[string]any)["version"].(float64))
+		}
+		if !reflect.DeepEqual(versions, []float64{1, 2, 3}) {
+			t.Errorf("expected versions 1 to 3 in one batch, got %v", versions)
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		server := newNotificationServer(t, 2, http.StatusServiceUnavailable)
+
+		cfg := New[TestConfig]()
+		notifier, err := NewNotifier(cfg,
+			WithWebhook(server.URL),
+			WithNotificationRetry(3, time.Millisecond),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		notifier.Start(ctx)
+
+		if err := cfg.Update(ctx, TestConfig{Value: "a"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		server.wait(t, 1)
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		server := newNotificationServer(t, 0, 0)
+
+		cfg := New[TestConfig]()
+		notifier, err := NewNotifier(cfg, WithWebhook(server.URL))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		startCtx, stop := context.WithCancel(ctx)
+		notifier.Start(startCtx)
+		notifier.Start(startCtx)
+
+		if err := cfg.Update(ctx, TestConfig{Value: "a"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		server.wait(t, 1)
+		time.Sleep(50 * time.Millisecond)
+		if bodies := server.wait(t, 1); len(bodies) != 1 {
+			t.Errorf("expected one notification after starting twice, got %d", len(bodies))
+		}
+
+		stop()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			cfg.mu.Lock()
+			n := len(cfg.listeners)
+			cfg.mu.Unlock()
+			if n == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expected the listener to be removed once stopped")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		handler := newRecordHandler()
+		cfg := New[TestConfig](WithLogger[TestConfig](slog.New(handler)))
+		notifier, err := NewNotifier(cfg,
+			WithSlackWebhook(server.URL+"/services/s3cr3t-token"),
+			WithNotificationRetry(1, time.Millisecond),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		notifier.Start(ctx)
+
+		if err := cfg.Update(ctx, TestConfig{Value: "a"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rec := handler.find(t, "notification failed")
+		if msg := fmt.Sprint(rec["error"]); strings.Contains(msg, "s3cr3t-token") {
+			t.Errorf("logged error exposes the webhook URL: %s", msg)
+		}
+	})
+
+	t.Run("client errors", func(t *testing.T) {
+		server := newNotificationServer(t, 1, http.StatusBadRequest)
+		n := &Notifier[TestConfig]{options: notifierOptions{attempts: 3, backoff: time.Millisecond}}
+
+		endpoint := &webhookEndpoint{url: server.URL, client: server.Client()}
+		if err := n.send(ctx, endpoint, NotificationBatch{}, ""); err == nil {
+			t.Fatal("expected an error")
+		}
+		// The request is not retried, so the next one succeeds
+		if err := n.send(ctx, endpoint, NotificationBatch{}, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		_, err := NewNotifier(New[TestConfig](), WithWebhook("http://example.com"), WithNotificationTemplate("{{"))
+		if err == nil {
+			t.Error("expected a template error")
+		}
+		if _, err := NewNotifier(New[TestConfig]()); err == nil {
+			t.Error("expected an error without endpoints")
+		}
+	})
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"database", "database", true},
+		{"database", "database.hosts[0]", true},
+		{"database.hosts", "database.hosts[1]", true},
+		{"database.hosts[1]", "database.hosts[1]", true},
+		{"database.hosts[0]", "database.hosts[1]", false},
+		{"*.timeout", "http.timeout", true},
+		{"*.timeout", "http.retries", false},
+		{"database.port", "database", false},
+		{"data", "database", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
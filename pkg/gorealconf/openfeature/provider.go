@@ -0,0 +1,334 @@
+// Package openfeature provides an OpenFeature provider that evaluates flags
+// from a gorealconf flag document.
+package openfeature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// ProviderName is reported in the provider's metadata and events
+const ProviderName = "gorealconf"
+
+// Provider is an OpenFeature provider backed by gorealconf.Flags. It
+// emits PROVIDER_CONFIGURATION_CHANGED whenever the flag document changes.
+// When events are not consumed and the Config drops the provider as a slow
+// subscriber, it emits PROVIDER_STALE and subscribes again.
+type Provider struct {
+	flags  *gorealconf.Flags
+	events chan of.Event
+
+	mu          sync.Mutex
+	cancel      context.CancelFunc
+	unsubscribe func()
+}
+
+var (
+	_ of.FeatureProvider = (*Provider)(nil)
+	_ of.StateHandler    = (*Provider)(nil)
+	_ of.EventHandler    = (*Provider)(nil)
+)
+
+func NewProvider(flags *gorealconf.Flags) *Provider {
+	return &Provider{
+		flags:  flags,
+		events: make(chan of.Event, 16),
+	}
+}
+
+func (p *Provider) Metadata() of.Metadata {
+	return of.Metadata{Name: ProviderName}
+}
+
+func (p *Provider) Hooks() []of.Hook {
+	return nil
+}
+
+func (p *Provider) EventChannel() <-chan of.Event {
+	return p.events
+}
+
+// Init starts watching the flag document. If it has not been loaded yet,
+// Init fails and the provider emits PROVIDER_READY once the first document
+// arrives.
+func (p *Provider) Init(of.EvaluationContext) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		return nil
+	}
+
+	config := p.flags.Config()
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, unsubscribe := config.Subscribe(ctx)
+	p.cancel = cancel
+	p.unsubscribe = unsubscribe
+
+	ready := config.Version() > 0
+	go p.watch(ctx, updates, config.Get(ctx), ready)
+
+	if !ready {
+		return &of.ProviderInitError{ErrorCode: of.ProviderNotReadyCode, Message: "flag document not loaded yet"}
+	}
+	return nil
+}
+
+// Shutdown stops watching the flag document
+func (p *Provider) Shutdown() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+		p.unsubscribe()
+		p.cancel, p.unsubscribe = nil, nil
+	}
+}
+
+// resubscribe replaces a subscription the Config dropped. It returns nil
+// once the provider is shut down.
+func (p *Provider) resubscribe(ctx context.Context) <-chan gorealconf.FlagDocument {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	updates, unsubscribe := p.flags.Config().Subscribe(ctx)
+	p.unsubscribe = unsubscribe
+	return updates
+}
+
+func (p *Provider) watch(ctx context.Context, updates <-chan gorealconf.FlagDocument, previous gorealconf.FlagDocument, ready bool) {
+	stale := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case doc, ok := <-updates:
+			if !ok {
+				// Dropped as a slow subscriber; updates may have been missed
+				p.emit(ctx, of.ProviderStale, "flag updates missed, resubscribing", nil)
+				if updates = p.resubscribe(ctx); updates == nil {
+					return
+				}
+				stale = true
+				continue
+			}
+			if !ready {
+				// Subscribe may deliver the value Init already saw
+				if p.flags.Config().Version() == 0 {
+					continue
+				}
+				ready = true
+				previous = doc
+				p.emit(ctx, of.ProviderReady, "flag document loaded", nil)
+				continue
+			}
+
+			changed := changedFlags(previous, doc)
+			previous = doc
+			switch {
+			case len(changed) > 0:
+				p.emit(ctx, of.ProviderConfigChange, "flag document updated", changed)
+			case stale:
+				p.emit(ctx, of.ProviderReady, "flag document resynchronized", nil)
+			}
+			stale = false
+		}
+	}
+}
+
+func (p *Provider) emit(ctx context.Context, eventType of.EventType, message string, flags []string) {
+	event := of.Event{
+		ProviderName: ProviderName,
+		EventType:    eventType,
+		ProviderEventDetails: of.ProviderEventDetails{
+			Message:     message,
+			FlagChanges: flags,
+		},
+	}
+	select {
+	case p.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// changedFlags returns the keys of flags added, removed or modified
+// between two documents
+func changedFlags(previous, current gorealconf.FlagDocument) []string {
+	var changed []string
+	for key, flag := range current.Flags {
+		if old, ok := previous.Flags[key]; !ok || !reflect.DeepEqual(old, flag) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous.Flags {
+		if _, ok := current.Flags[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (p *Provider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx of.FlattenedContext) of.BoolResolutionDetail {
+	eval, detail := p.evaluate(ctx, flag, evalCtx)
+	if detail.Error() != nil {
+		return of.BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+
+	value, ok := eval.Value.(bool)
+	if !ok {
+		return of.BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(eval, "bool")}
+	}
+	return of.BoolResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) StringEvaluation(ctx context.Context, flag string, defaultValue string, evalCtx of.FlattenedContext) of.StringResolutionDetail {
+	eval, detail := p.evaluate(ctx, flag, evalCtx)
+	if detail.Error() != nil {
+		return of.StringResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+
+	value, ok := eval.Value.(string)
+	if !ok {
+		return of.StringResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(eval, "string")}
+	}
+	return of.StringResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) FloatEvaluation(ctx context.Context, flag string, defaultValue float64, evalCtx of.FlattenedContext) of.FloatResolutionDetail {
+	eval, detail := p.evaluate(ctx, flag, evalCtx)
+	if detail.Error() != nil {
+		return of.FloatResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+
+	var value float64
+	switch v := eval.Value.(type) {
+	case float64:
+		value = v
+	case int:
+		value = float64(v)
+	case int64:
+		value = float64(v)
+	default:
+		return of.FloatResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(eval, "number")}
+	}
+	return of.FloatResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, evalCtx of.FlattenedContext) of.IntResolutionDetail {
+	eval, detail := p.evaluate(ctx, flag, evalCtx)
+	if detail.Error() != nil {
+		return of.IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+
+	var value int64
+	switch v := eval.Value.(type) {
+	case int:
+		value = int64(v)
+	case int64:
+		value = v
+	case float64:
+		// JSON numbers decode as float64
+		if v != math.Trunc(v) {
+			return of.IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(eval, "integer")}
+		}
+		value = int64(v)
+	default:
+		return of.IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(eval, "integer")}
+	}
+	return of.IntResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+// ObjectEvaluation returns the variant's value as decoded from the flag
+// document, e.g. a map[string]any for JSON objects
+func (p *Provider) ObjectEvaluation(ctx context.Context, flag string, defaultValue interface{}, evalCtx of.FlattenedContext) of.InterfaceResolutionDetail {
+	eval, detail := p.evaluate(ctx, flag, evalCtx)
+	if detail.Error() != nil {
+		return of.InterfaceResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return of.InterfaceResolutionDetail{Value: eval.Value, ProviderResolutionDetail: detail}
+}
+
+// evaluate resolves flag for the subject described by evalCtx, or the
+// subject already in ctx when evalCtx is empty
+func (p *Provider) evaluate(ctx context.Context, flag string, evalCtx of.FlattenedContext) (gorealconf.FlagEvaluation, of.ProviderResolutionDetail) {
+	if len(evalCtx) > 0 {
+		ctx = gorealconf.WithSubject(ctx, subjectFrom(evalCtx))
+	}
+
+	eval := p.flags.Evaluate(ctx, flag)
+	detail := of.ProviderResolutionDetail{
+		Reason:  reason(eval.Reason),
+		Variant: eval.Variant,
+	}
+
+	var notFound *gorealconf.FlagNotFoundError
+	switch {
+	case errors.As(eval.Error, &notFound):
+		detail.ResolutionError = of.NewFlagNotFoundResolutionError(notFound.Error())
+	case eval.Error != nil:
+		detail.ResolutionError = of.NewGeneralResolutionError(eval.Error.Error())
+	}
+	return eval, detail
+}
+
+// subjectFrom converts an evaluation context to a subject. The targeting
+// key becomes the ID; other scalar attributes are formatted as strings so
+// targeting rules can match them.
+func subjectFrom(evalCtx of.FlattenedContext) gorealconf.Subject {
+	subject := gorealconf.Subject{Attributes: make(map[string]string, len(evalCtx))}
+	for key, value := range evalCtx {
+		if key == of.TargetingKey {
+			subject.ID, _ = value.(string)
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			subject.Attributes[key] = v
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			subject.Attributes[key] = fmt.Sprint(v)
+		}
+	}
+	return subject
+}
+
+// reason maps gorealconf reasons to OpenFeature's: rules are targeting
+// matches, weighted rollouts are splits
+func reason(r string) of.Reason {
+	switch r {
+	case gorealconf.ReasonDisabled:
+		return of.DisabledReason
+	case gorealconf.ReasonTargetingMatch:
+		return of.TargetingMatchReason
+	case gorealconf.ReasonSplit:
+		return of.SplitReason
+	case gorealconf.ReasonStatic:
+		return of.StaticReason
+	case gorealconf.ReasonDefault:
+		return of.DefaultReason
+	case gorealconf.ReasonError:
+		return of.ErrorReason
+	default:
+		return of.UnknownReason
+	}
+}
+
+func typeMismatch(eval gorealconf.FlagEvaluation, want string) of.ProviderResolutionDetail {
+	return of.ProviderResolutionDetail{
+		ResolutionError: of.NewTypeMismatchResolutionError(fmt.Sprintf("flag %s: variant %q is not a %s", eval.Key, eval.Variant, want)),
+		Reason:          of.ErrorReason,
+		Variant:         eval.Variant,
+	}
+}
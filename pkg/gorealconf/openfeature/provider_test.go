@@ -0,0 +1,236 @@
+package openfeature
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+const testFlags = `{"flags": {
+  "new_ui": {
+    "enabled": true,
+    "rules": [{"match": {"attribute": "tier", "op": "eq", "value": "beta"}, "variant": "on"}],
+    "fallthrough": {"rollout": [{"variant": "on", "weight": 50}, {"variant": "off", "weight": 50}]}
+  },
+  "checkout": {
+    "enabled": true,
+    "variants": {"control": "one-page", "treatment": "multi-step"},
+    "default_variant": "control",
+    "fallthrough": {"variant": "treatment"}
+  },
+  "max_items": {
+    "enabled": true,
+    "variants": {"small": 10, "large": 50.5},
+    "default_variant": "small",
+    "fallthrough": {"variant": "small"}
+  },
+  "theme": {
+    "enabled": true,
+    "variants": {"dark": {"background": "black"}},
+    "default_variant": "dark",
+    "fallthrough": {"variant": "dark"}
+  },
+  "kill_switch": {"enabled": false}
+}}`
+
+func loadFlags(t *testing.T, data string) gorealconf.FlagDocument {
+	t.Helper()
+	var doc gorealconf.FlagDocument
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return doc
+}
+
+func TestProviderEvaluation(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := gorealconf.New[gorealconf.FlagDocument]()
+	if err := cfg.Update(ctx, loadFlags(t, testFlags)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider := NewProvider(gorealconf.NewFlags(cfg))
+
+	beta := of.FlattenedContext{of.TargetingKey: "user-1", "tier": "beta"}
+
+	t.Run("boolean", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			flag       string
+			evalCtx    of.FlattenedContext
+			want       bool
+			wantReason of.Reason
+			wantCode   of.ErrorCode
+		}{
+			{"targeting", "new_ui", beta, true, of.TargetingMatchReason, ""},
+			{"no subject", "new_ui", nil, false, of.DefaultReason, ""},
+			{"disabled", "kill_switch", beta, false, of.DisabledReason, ""},
+			{"missing", "unknown", beta, true, of.ErrorReason, of.FlagNotFoundCode},
+			{"wrong type", "checkout", beta, true, of.ErrorReason, of.TypeMismatchCode},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				detail := provider.BooleanEvaluation(ctx, tt.flag, true, tt.evalCtx)
+				if detail.Value != tt.want || detail.Reason != tt.wantReason {
+					t.Errorf("expected %v (%s), got %v (%s)", tt.want, tt.wantReason, detail.Value, detail.Reason)
+				}
+				if code := detail.ResolutionDetail().ErrorCode; code != tt.wantCode {
+					t.Errorf("expected error code %q, got %q", tt.wantCode, code)
+				}
+			})
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		detail := provider.BooleanEvaluation(ctx, "new_ui", false, of.FlattenedContext{of.TargetingKey: "user-2"})
+		if detail.Reason != of.SplitReason {
+			t.Errorf("expected split, got %s", detail.Reason)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		detail := provider.StringEvaluation(ctx, "checkout", "", beta)
+		if detail.Value != "multi-step" || detail.Variant != "treatment" || detail.Reason != of.StaticReason {
+			t.Errorf("unexpected resolution %+v", detail)
+		}
+	})
+
+	t.Run("numbers", func(t *testing.T) {
+		if detail := provider.IntEvaluation(ctx, "max_items", 0, beta); detail.Value != 10 {
+			t.Errorf("expected 10, got %+v", detail)
+		}
+		if detail := provider.FloatEvaluation(ctx, "max_items", 0, beta); detail.Value != 10 {
+			t.Errorf("expected 10, got %+v", detail)
+		}
+	})
+
+	t.Run("object", func(t *testing.T) {
+		detail := provider.ObjectEvaluation(ctx, "theme", nil, beta)
+		want := map[string]any{"background": "black"}
+		if !reflect.DeepEqual(detail.Value, want) {
+			t.Errorf("expected %v, got %v", want, detail.Value)
+		}
+	})
+
+	t.Run("client", func(t *testing.T) {
+		if err := of.SetNamedProviderAndWait(t.Name(), provider); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		client := of.NewClient(t.Name())
+
+		evalCtx := of.NewEvaluationContext("user-1", map[string]any{"tier": "beta"})
+		enabled, err := client.BooleanValue(ctx, "new_ui", false, evalCtx)
+		if err != nil || !enabled {
+			t.Errorf("expected new_ui enabled, got %v (%v)", enabled, err)
+		}
+	})
+}
+
+func TestProviderEvents(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := gorealconf.New[gorealconf.FlagDocument]()
+	provider := NewProvider(gorealconf.NewFlags(cfg))
+	defer provider.Shutdown()
+
+	// The document is not loaded yet
+	if err := provider.Init(of.EvaluationContext{}); err == nil {
+		t.Fatal("expected init error before the first document")
+	}
+
+	next := func() of.Event {
+		t.Helper()
+		select {
+		case event := <-provider.EventChannel():
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+			return of.Event{}
+		}
+	}
+
+	doc := loadFlags(t, testFlags)
+	if err := cfg.Update(ctx, doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event := next(); event.EventType != of.ProviderReady {
+		t.Errorf("expected ready event, got %+v", event)
+	}
+
+	changed := loadFlags(t, testFlags)
+	changed.Flags["kill_switch"] = gorealconf.Flag{Enabled: true}
+	delete(changed.Flags, "theme")
+	if err := cfg.Update(ctx, changed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	event := next()
+	if event.EventType != of.ProviderConfigChange {
+		t.Fatalf("expected configuration changed event, got %+v", event)
+	}
+	if want := []string{"kill_switch", "theme"}; !reflect.DeepEqual(event.FlagChanges, want) {
+		t.Errorf("expected changes %v, got %v", want, event.FlagChanges)
+	}
+}
+
+func TestProviderResubscribes(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := gorealconf.New[gorealconf.FlagDocument]()
+	if err := cfg.Update(ctx, loadFlags(t, testFlags)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider := NewProvider(gorealconf.NewFlags(cfg))
+	defer provider.Shutdown()
+	if err := provider.Init(of.EvaluationContext{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Without a consumer the event buffer fills, the provider stops
+	// reading its subscription and the Config drops it
+	for i := 0; i < 64; i++ {
+		doc := loadFlags(t, testFlags)
+		doc.Flags["kill_switch"] = gorealconf.Flag{Enabled: i%2 == 0}
+		if err := cfg.Update(ctx, doc); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	next := func() of.Event {
+		t.Helper()
+		select {
+		case event := <-provider.EventChannel():
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+			return of.Event{}
+		}
+	}
+	for event := next(); event.EventType != of.ProviderStale; event = next() {
+	}
+	if event := next(); event.EventType != of.ProviderConfigChange && event.EventType != of.ProviderReady {
+		t.Fatalf("expected the provider to resynchronize, got %+v", event)
+	}
+
+	// Updates are delivered again after resubscribing
+	doc := loadFlags(t, testFlags)
+	doc.Flags["kill_switch"] = cfg.Get(ctx).Flags["kill_switch"]
+	delete(doc.Flags, "theme")
+	if err := cfg.Update(ctx, doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Notifications of earlier updates may still be in flight
+	for {
+		event := next()
+		if event.EventType == of.ProviderConfigChange && slices.Contains(event.FlagChanges, "theme") {
+			break
+		}
+	}
+}
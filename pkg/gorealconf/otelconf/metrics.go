@@ -0,0 +1,240 @@
+package otelconf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// Metrics implements gorealconf.MetricsRecorder with OpenTelemetry
+// instruments. Measurements carry the source, and the config name when
+// set with WithConfigName.
+type Metrics struct {
+	config []attribute.KeyValue
+
+	updates          metric.Int64Counter
+	version          metric.Int64Gauge
+	updateDuration   metric.Float64Histogram
+	lastUpdate       metric.Float64Gauge
+	loadErrors       metric.Int64Counter
+	watchErrors      metric.Int64Counter
+	reconnects       metric.Int64Counter
+	updateErrors     metric.Int64Counter
+	decodeErrors     metric.Int64Counter
+	validationErrors metric.Int64Counter
+	verifyFailures   metric.Int64Counter
+	rollbacks        metric.Int64Counter
+	subscribers      metric.Int64Gauge
+	subscriberLag    metric.Float64Histogram
+	rolloutStage     metric.Int64Gauge
+	rolloutVariants  metric.Float64Gauge
+	variantsServed   metric.Int64Counter
+	exposures        metric.Int64Counter
+	flagEvals        metric.Int64Counter
+
+	now     func() time.Time
+	mu      sync.Mutex
+	updated map[string]time.Time
+}
+
+var _ gorealconf.MetricsRecorder = (*Metrics)(nil)
+
+type options struct {
+	config string
+}
+
+type Option func(*options)
+
+// WithConfigName sets the gorealconf.config attribute on every
+// measurement, so several configs can share a meter
+func WithConfigName(name string) Option {
+	return func(o *options) {
+		o.config = name
+	}
+}
+
+// NewMetrics creates the instruments with meter, or the global meter
+// provider's when meter is nil
+func NewMetrics(meter metric.Meter, opts ...Option) (*Metrics, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if meter == nil {
+		meter = otel.GetMeterProvider().Meter(ScopeName)
+	}
+
+	m := &Metrics{
+		now:     time.Now,
+		updated: make(map[string]time.Time),
+	}
+	if o.config != "" {
+		m.config = []attribute.KeyValue{ConfigKey.String(o.config)}
+	}
+
+	var errs []error
+	counter := func(name, desc string) metric.Int64Counter {
+		c, err := meter.Int64Counter(name, metric.WithDescription(desc))
+		errs = append(errs, err)
+		return c
+	}
+	gauge := func(name, desc string) metric.Int64Gauge {
+		g, err := meter.Int64Gauge(name, metric.WithDescription(desc))
+		errs = append(errs, err)
+		return g
+	}
+	floatGauge := func(name, desc, unit string) metric.Float64Gauge {
+		g, err := meter.Float64Gauge(name, metric.WithDescription(desc), metric.WithUnit(unit))
+		errs = append(errs, err)
+		return g
+	}
+	histogram := func(name, desc string) metric.Float64Histogram {
+		h, err := meter.Float64Histogram(name, metric.WithDescription(desc), metric.WithUnit("s"))
+		errs = append(errs, err)
+		return h
+	}
+
+	m.updates = counter("gorealconf.updates", "Number of configuration updates")
+	m.version = gauge("gorealconf.version", "Current configuration version")
+	m.updateDuration = histogram("gorealconf.update.duration", "Duration of configuration updates")
+	m.lastUpdate = floatGauge("gorealconf.update.last", "Unix time of the last successful update per source", "s")
+	m.loadErrors = counter("gorealconf.load.errors", "Number of configuration loading errors")
+	m.watchErrors = counter("gorealconf.watch.errors", "Number of configuration watch errors")
+	m.reconnects = counter("gorealconf.watch.reconnects", "Number of times a source watch was restarted")
+	m.updateErrors = counter("gorealconf.update.errors", "Number of configuration update errors")
+	m.decodeErrors = counter("gorealconf.decode.errors", "Number of payloads that could not be decoded")
+	m.validationErrors = counter("gorealconf.validation.errors", "Number of validation errors")
+	m.verifyFailures = counter("gorealconf.signature.failures", "Number of payloads rejected by signature verification")
+	m.rollbacks = counter("gorealconf.rollbacks", "Number of configuration rollbacks")
+	m.subscribers = gauge("gorealconf.subscribers", "Current number of subscribers")
+	m.subscriberLag = histogram("gorealconf.subscriber.lag", "Delay between applying an update and notifying subscribers")
+	m.rolloutStage = gauge("gorealconf.rollout.stage", "Current rollout stage, starting at 1, or 0 when no rollout is in progress")
+	m.rolloutVariants = floatGauge("gorealconf.rollout.variant.percentage", "Percentage of traffic served per rollout variant", "%")
+	m.variantsServed = counter("gorealconf.rollout.variant.served", "Number of requests served per rollout variant")
+	m.exposures = counter("gorealconf.experiment.exposures", "Number of experiment exposures per variant")
+	m.flagEvals = counter("gorealconf.flag.evaluations", "Number of feature flag evaluations per variant")
+
+	_, err := meter.Float64ObservableGauge("gorealconf.staleness",
+		metric.WithDescription("Seconds since the last successful update per source"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(m.observeStaleness),
+	)
+	errs = append(errs, err)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Metrics) observeStaleness(_ context.Context, o metric.Float64Observer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for source, t := range m.updated {
+		o.Observe(now.Sub(t).Seconds(), m.attrs(SourceKey.String(source)))
+	}
+	return nil
+}
+
+// attrs returns the measurement attributes kv plus the config name
+func (m *Metrics) attrs(kv ...attribute.KeyValue) metric.MeasurementOption {
+	return metric.WithAttributes(append(kv, m.config...)...)
+}
+
+func (m *Metrics) source(source string) metric.MeasurementOption {
+	return m.attrs(SourceKey.String(source))
+}
+
+func (m *Metrics) IncConfigUpdates(source, valid string) {
+	m.updates.Add(context.Background(), 1, m.attrs(SourceKey.String(source), attribute.String("valid", valid)))
+}
+
+func (m *Metrics) SetConfigVersion(version float64) {
+	m.version.Record(context.Background(), int64(version), m.attrs())
+}
+
+func (m *Metrics) ObserveUpdateDuration(duration float64) {
+	m.updateDuration.Record(context.Background(), duration, m.attrs())
+}
+
+func (m *Metrics) SetLastUpdate(source string, t time.Time) {
+	m.lastUpdate.Record(context.Background(), float64(t.UnixNano())/1e9, m.source(source))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updated[source] = t
+}
+
+func (m *Metrics) IncLoadErrors(source string) {
+	m.loadErrors.Add(context.Background(), 1, m.source(source))
+}
+
+func (m *Metrics) IncWatchErrors(source string) {
+	m.watchErrors.Add(context.Background(), 1, m.source(source))
+}
+
+func (m *Metrics) IncWatchReconnects(source string) {
+	m.reconnects.Add(context.Background(), 1, m.source(source))
+}
+
+func (m *Metrics) IncUpdateErrors(source string) {
+	m.updateErrors.Add(context.Background(), 1, m.source(source))
+}
+
+func (m *Metrics) IncDecodeErrors(source string) {
+	m.decodeErrors.Add(context.Background(), 1, m.source(source))
+}
+
+func (m *Metrics) IncValidationErrors(source string) {
+	m.validationErrors.Add(context.Background(), 1, m.source(source))
+}
+
+func (m *Metrics) IncVerificationFailures() {
+	m.verifyFailures.Add(context.Background(), 1, m.attrs())
+}
+
+func (m *Metrics) IncRollbackCount() {
+	m.rollbacks.Add(context.Background(), 1, m.attrs())
+}
+
+func (m *Metrics) SetSubscribers(count int) {
+	m.subscribers.Record(context.Background(), int64(count), m.attrs())
+}
+
+func (m *Metrics) ObserveSubscriberLag(lag float64) {
+	m.subscriberLag.Record(context.Background(), lag, m.attrs())
+}
+
+func (m *Metrics) SetRolloutStage(stage int, percentage float64) {
+	ctx := context.Background()
+	m.rolloutStage.Record(ctx, int64(stage+1), m.attrs())
+	m.rolloutVariants.Record(ctx, percentage, m.attrs(attribute.String("variant", gorealconf.VariantCandidate)))
+	m.rolloutVariants.Record(ctx, 100-percentage, m.attrs(attribute.String("variant", gorealconf.VariantStable)))
+}
+
+func (m *Metrics) IncVariantServed(variant string) {
+	m.variantsServed.Add(context.Background(), 1, m.attrs(attribute.String("variant", variant)))
+}
+
+func (m *Metrics) IncExperimentExposure(experiment, variant string) {
+	m.exposures.Add(context.Background(), 1, m.attrs(
+		attribute.String("experiment", experiment),
+		attribute.String("variant", variant),
+	))
+}
+
+func (m *Metrics) IncFlagEvaluation(flag, variant string) {
+	m.flagEvals.Add(context.Background(), 1, m.attrs(
+		attribute.String("flag", flag),
+		attribute.String("variant", variant),
+	))
+}
@@ -0,0 +1,74 @@
+package otelconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+func TestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	metrics, err := NewMetrics(provider.Meter(ScopeName), WithConfigName("app"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	metrics.now = func() time.Time { return time.Now().Add(time.Minute) }
+
+	ctx := context.Background()
+	cfg := gorealconf.New[TestConfig](gorealconf.WithMetrics[TestConfig](metrics))
+	for _, value := range []string{"one", "two"} {
+		if err := cfg.Update(ctx, TestConfig{Value: value}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := make(map[string]metricdata.Aggregation)
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			found[m.Name] = m.Data
+		}
+	}
+
+	updates, ok := found["gorealconf.updates"].(metricdata.Sum[int64])
+	if !ok || len(updates.DataPoints) != 1 {
+		t.Fatalf("expected one update series, got %+v", found["gorealconf.updates"])
+	}
+	point := updates.DataPoints[0]
+	if point.Value != 2 {
+		t.Errorf("expected 2 updates, got %d", point.Value)
+	}
+	want := attribute.NewSet(
+		ConfigKey.String("app"),
+		SourceKey.String(gorealconf.SourceManual),
+		attribute.String("valid", "true"),
+	)
+	if !point.Attributes.Equals(&want) {
+		t.Errorf("unexpected attributes %v", point.Attributes.ToSlice())
+	}
+
+	version, ok := found["gorealconf.version"].(metricdata.Gauge[int64])
+	if !ok || len(version.DataPoints) != 1 || version.DataPoints[0].Value != 2 {
+		t.Errorf("expected version 2, got %+v", found["gorealconf.version"])
+	}
+
+	staleness, ok := found["gorealconf.staleness"].(metricdata.Gauge[float64])
+	if !ok || len(staleness.DataPoints) != 1 {
+		t.Fatalf("expected one staleness series, got %+v", found["gorealconf.staleness"])
+	}
+	if v := staleness.DataPoints[0].Value; v < 59 || v > 61 {
+		t.Errorf("expected about 60s of staleness, got %v", v)
+	}
+}
@@ -0,0 +1,59 @@
+// Package otelconf exports gorealconf traces and metrics through
+// OpenTelemetry.
+package otelconf
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+// ScopeName is the instrumentation scope of the tracer and meter
+const ScopeName = "github.com/samuelarogbonlo/gorealconf"
+
+// Attribute keys set on spans and measurements
+const (
+	ConfigKey  = attribute.Key("gorealconf.config")
+	SourceKey  = attribute.Key("gorealconf.source")
+	VersionKey = attribute.Key("gorealconf.version")
+)
+
+// Tracer implements gorealconf.Tracer with an OpenTelemetry tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ gorealconf.Tracer = (*Tracer)(nil)
+
+// NewTracer traces with provider, or the global tracer provider when
+// provider is nil
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(ScopeName)}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, attrs gorealconf.SpanAttributes) (context.Context, func(error)) {
+	kv := []attribute.KeyValue{SourceKey.String(attrs.Source)}
+	if attrs.Config != "" {
+		kv = append(kv, ConfigKey.String(attrs.Config))
+	}
+	if attrs.Version > 0 {
+		kv = append(kv, VersionKey.Int64(int64(attrs.Version)))
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(kv...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
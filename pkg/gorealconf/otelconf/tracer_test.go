@@ -0,0 +1,143 @@
+package otelconf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/samuelarogbonlo/gorealconf/pkg/gorealconf"
+)
+
+type TestConfig struct {
+	Value string `json:"value"`
+}
+
+// payloadSource serves a fixed payload through gorealconf.RawSource
+type payloadSource struct {
+	data []byte
+}
+
+func (s *payloadSource) Load(ctx context.Context) (TestConfig, error) {
+	return TestConfig{}, errors.New("not used")
+}
+
+func (s *payloadSource) Watch(ctx context.Context) (<-chan TestConfig, error) {
+	return nil, errors.New("not used")
+}
+
+func (s *payloadSource) LoadRaw(ctx context.Context) ([]byte, error) {
+	return s.data, nil
+}
+
+func (s *payloadSource) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	return make(chan []byte), nil
+}
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan trace.SpanContext, 1)
+	cfg := gorealconf.New[TestConfig](
+		gorealconf.WithName[TestConfig]("app"),
+		gorealconf.WithNamedSource[TestConfig]("primary", &payloadSource{data: []byte(`{"value":"initial"}`)}),
+		gorealconf.WithValidation(func(_, new TestConfig) error {
+			if new.Value == "" {
+				return errors.New("value is required")
+			}
+			return nil
+		}),
+		gorealconf.WithTracer[TestConfig](NewTracer(provider)),
+	)
+	cfg.OnChange(func(ctx context.Context, event gorealconf.ChangeEvent[TestConfig]) {
+		changed <- trace.SpanContextFromContext(ctx)
+	})
+
+	if err := cfg.Load(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var listener trace.SpanContext
+	select {
+	case listener = <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for change event")
+	}
+
+	// The notify span ends after the listener returns
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Ended()) < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 5 spans, got %d", len(recorder.Ended()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	parents := map[string]string{
+		gorealconf.SpanDecode:   gorealconf.SpanLoad,
+		gorealconf.SpanApply:    gorealconf.SpanLoad,
+		gorealconf.SpanValidate: gorealconf.SpanApply,
+		gorealconf.SpanNotify:   gorealconf.SpanApply,
+	}
+	for child, parent := range parents {
+		c, p := spans[child], spans[parent]
+		if c == nil || p == nil {
+			t.Fatalf("missing span %s or %s", child, parent)
+		}
+		if c.Parent().SpanID() != p.SpanContext().SpanID() {
+			t.Errorf("expected %s to be a child of %s", child, parent)
+		}
+	}
+
+	for name, span := range spans {
+		attrs := make(map[string]string)
+		for _, kv := range span.Attributes() {
+			attrs[string(kv.Key)] = kv.Value.Emit()
+		}
+		if attrs[string(SourceKey)] != "primary" || attrs[string(ConfigKey)] != "app" {
+			t.Errorf("span %s: unexpected attributes %v", name, attrs)
+		}
+	}
+	if version := spans[gorealconf.SpanNotify].Attributes(); len(version) != 3 {
+		t.Errorf("expected the notify span to carry the version, got %v", version)
+	}
+
+	if listener.SpanID() != spans[gorealconf.SpanNotify].SpanContext().SpanID() {
+		t.Error("expected the change listener to run in the notify span")
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		cfg := gorealconf.New[TestConfig](
+			gorealconf.WithValidation(func(_, new TestConfig) error {
+				return errors.New("rejected")
+			}),
+			gorealconf.WithRollback[TestConfig](true),
+			gorealconf.WithTracer[TestConfig](NewTracer(provider)),
+		)
+		if err := cfg.Update(ctx, TestConfig{Value: "bad"}); err == nil {
+			t.Fatal("expected validation error")
+		}
+
+		for _, span := range recorder.Ended() {
+			if span.Status().Description == "" {
+				t.Errorf("expected span %s to record the error", span.Name())
+			}
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package gorealconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+)
+
+// RedactedValue replaces sensitive values in every output produced by
+// gorealconf
+const RedactedValue = "[REDACTED]"
+
+var redactedJSON = []byte(`"` + RedactedValue + `"`)
+
+// Redacted returns a copy of value with sensitive data masked: string fields
+// tagged `secret:"true"` are set to RedactedValue, other tagged fields are
+// zeroed, and Sensitive values print and marshal as RedactedValue. Use it
+// whenever a configuration is logged or otherwise leaves the process.
+func Redacted[T any](value T) T {
+	v := reflect.ValueOf(&value).Elem()
+	return redactValue(v).Interface().(T)
+}
+
+func redactValue(v reflect.Value) reflect.Value {
+	if r, ok := asRedactable(v); ok {
+		return reflect.ValueOf(r.redact())
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(redactValue(v.Elem()))
+		return out
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(redactValue(v.Elem()))
+		return out
+
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if isSecretField(f) {
+				out.Field(i).Set(maskValue(v.Field(i)))
+				continue
+			}
+			out.Field(i).Set(redactValue(v.Field(i)))
+		}
+		return out
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return out
+
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return out
+	}
+
+	return v
+}
+
+// maskValue returns the masked form of a field tagged as secret
+func maskValue(v reflect.Value) reflect.Value {
+	if r, ok := asRedactable(v); ok {
+		return reflect.ValueOf(r.redact())
+	}
+
+	out := reflect.New(v.Type()).Elem()
+	if v.Kind() == reflect.String {
+		out.SetString(RedactedValue)
+	}
+	return out
+}
+
+// mergeRedacted restores values in dst that still hold the masked form
+// produced by Redacted from the corresponding values in cur. It lets a
+// redacted configuration be edited and written back without clobbering
+// its secrets. dst is modified in place.
+func mergeRedacted[T any](dst *T, cur T) {
+	mergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(&cur).Elem())
+}
+
+func mergeValue(dst, cur reflect.Value) {
+	if r, ok := asRedactable(dst); ok {
+		if r.isRedacted() {
+			dst.Set(cur)
+		}
+		return
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !dst.IsNil() && !cur.IsNil() && dst.Elem().Type() == cur.Elem().Type() {
+			if dst.Kind() == reflect.Pointer {
+				mergeValue(dst.Elem(), cur.Elem())
+				return
+			}
+			elem := reflect.New(dst.Elem().Type()).Elem()
+			elem.Set(dst.Elem())
+			mergeValue(elem, cur.Elem())
+			dst.Set(elem)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < dst.NumField(); i++ {
+			f := dst.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if isSecretField(f) && isMasked(dst.Field(i)) {
+				dst.Field(i).Set(cur.Field(i))
+				continue
+			}
+			mergeValue(dst.Field(i), cur.Field(i))
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < dst.Len() && i < cur.Len(); i++ {
+			mergeValue(dst.Index(i), cur.Index(i))
+		}
+
+	case reflect.Map:
+		if dst.IsNil() || cur.IsNil() {
+			return
+		}
+		iter := dst.MapRange()
+		for iter.Next() {
+			curValue := cur.MapIndex(iter.Key())
+			if !curValue.IsValid() {
+				continue
+			}
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			mergeValue(elem, curValue)
+			dst.SetMapIndex(iter.Key(), elem)
+		}
+	}
+}
+
+func isMasked(v reflect.Value) bool {
+	if r, ok := asRedactable(v); ok {
+		return r.isRedacted()
+	}
+	if v.Kind() == reflect.String {
+		return v.String() == RedactedValue
+	}
+	return v.IsZero()
+}
+
+func isSecretField(f reflect.StructField) bool {
+	return f.Tag.Get("secret") == "true"
+}
+
+// redactable is implemented by Sensitive
+type redactable interface {
+	redact() any
+	isRedacted() bool
+}
+
+func asRedactable(v reflect.Value) (redactable, bool) {
+	if !v.IsValid() || v.Kind() != reflect.Struct || !v.CanInterface() {
+		return nil, false
+	}
+	r, ok := v.Interface().(redactable)
+	return r, ok
+}
+
+// Sensitive holds a value that must never be printed. It formats as
+// RedactedValue with every fmt verb and in log/slog, while Value returns the
+// real value. It marshals to JSON as the real value so configurations can
+// be stored and distributed, unless it was produced by Redacted.
+type Sensitive[T any] struct {
+	value    T
+	redacted bool
+}
+
+func NewSensitive[T any](value T) Sensitive[T] {
+	return Sensitive[T]{value: value}
+}
+
+// Value returns the wrapped value
+func (s Sensitive[T]) Value() T {
+	return s.value
+}
+
+func (s Sensitive[T]) String() string {
+	return RedactedValue
+}
+
+func (s Sensitive[T]) GoString() string {
+	return RedactedValue
+}
+
+func (s Sensitive[T]) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, RedactedValue)
+}
+
+func (s Sensitive[T]) LogValue() slog.Value {
+	return slog.StringValue(RedactedValue)
+}
+
+func (s Sensitive[T]) MarshalJSON() ([]byte, error) {
+	if s.redacted {
+		return redactedJSON, nil
+	}
+	return json.Marshal(s.value)
+}
+
+func (s *Sensitive[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), redactedJSON) {
+		var zero T
+		s.value = zero
+		s.redacted = true
+		return nil
+	}
+	s.redacted = false
+	return json.Unmarshal(data, &s.value)
+}
+
+func (s Sensitive[T]) redact() any {
+	var zero T
+	return Sensitive[T]{value: zero, redacted: true}
+}
+
+func (s Sensitive[T]) isRedacted() bool {
+	return s.redacted
+}
+
+func (s Sensitive[T]) wrappedValue() reflect.Value {
+	return reflect.ValueOf(&s.value).Elem()
+}
+
+func (s Sensitive[T]) rewrapValue(v reflect.Value) any {
+	return Sensitive[T]{value: v.Interface().(T), redacted: s.redacted}
+}
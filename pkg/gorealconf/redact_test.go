@@ -0,0 +1,145 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	type Database struct {
+		User     string            `json:"user"`
+		Password string            `json:"password" secret:"true"`
+		Token    Sensitive[string] `json:"token"`
+		Keys     []byte            `json:"keys" secret:"true"`
+	}
+	type TestConfig struct {
+		Primary  Database            `json:"primary"`
+		Replicas map[string]Database `json:"replicas"`
+	}
+
+	value := TestConfig{
+		Primary: Database{
+			User:     "app",
+			Password: "hunter2",
+			Token:    NewSensitive("s3cr3t"),
+			Keys:     []byte("key"),
+		},
+		Replicas: map[string]Database{
+			"eu": {User: "ro", Password: "replica-pass"},
+		},
+	}
+
+	t.Run("Redacted", func(t *testing.T) {
+		redacted := Redacted(value)
+		if redacted.Primary.Password != RedactedValue || redacted.Replicas["eu"].Password != RedactedValue {
+			t.Errorf("expected passwords to be redacted, got %+v", redacted)
+		}
+		if redacted.Primary.Keys != nil {
+			t.Errorf("expected keys to be zeroed, got %v", redacted.Primary.Keys)
+		}
+		if redacted.Primary.User != "app" {
+			t.Errorf("expected user to be kept, got %q", redacted.Primary.User)
+		}
+		if value.Primary.Password != "hunter2" || value.Primary.Token.Value() != "s3cr3t" {
+			t.Error("Redacted modified its input")
+		}
+
+		data, err := json.Marshal(redacted)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, secret := range []string{"hunter2", "s3cr3t", "replica-pass"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("marshalled config contains %q: %s", secret, data)
+			}
+		}
+	})
+
+	t.Run("Sensitive", func(t *testing.T) {
+		for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q"} {
+			if out := fmt.Sprintf(format, value); strings.Contains(out, "s3cr3t") {
+				t.Errorf("%s leaks sensitive value: %s", format, out)
+			}
+		}
+
+		data, err := json.Marshal(value.Primary.Token)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var decoded Sensitive[string]
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded.Value() != "s3cr3t" {
+			t.Errorf("expected round trip, got %q (%v)", decoded.Value(), err)
+		}
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		cfg := New[TestConfig](
+			WithRollback[TestConfig](true),
+			WithValidation[TestConfig](func(old, new TestConfig) error {
+				return errors.New("rejected")
+			}),
+		)
+
+		err := cfg.Update(context.Background(), value)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("expected *ValidationError, got %v", err)
+		}
+		if out := fmt.Sprintf("%+v", validationErr); strings.Contains(out, "hunter2") {
+			t.Errorf("validation error leaks secret: %s", out)
+		}
+	})
+
+	t.Run("admin round trip", func(t *testing.T) {
+		ctx := context.Background()
+		cfg := New[TestConfig](WithHistory[TestConfig](5))
+		if err := cfg.Update(ctx, value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		server := httptest.NewServer(NewAdminHandler(cfg))
+		defer server.Close()
+
+		for _, path := range []string{"/config", "/config/history"} {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "s3cr3t") {
+				t.Errorf("GET %s leaks secrets: %s", path, data)
+			}
+		}
+
+		// Writing back a redacted document keeps the current secrets
+		edited := Redacted(value)
+		edited.Primary.User = "admin"
+		body, _ := json.Marshal(edited)
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/config", strings.NewReader(string(body)))
+		req.Header.Set("If-Match", `"1"`)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		got := cfg.Get(ctx)
+		if got.Primary.User != "admin" || got.Primary.Password != "hunter2" || got.Primary.Token.Value() != "s3cr3t" {
+			t.Errorf("unexpected config after write back: user=%q", got.Primary.User)
+		}
+		if string(got.Primary.Keys) != "key" || got.Replicas["eu"].Password != "replica-pass" {
+			t.Error("write back clobbered secrets")
+		}
+	})
+}
@@ -1,16 +1,113 @@
 // rollout.go
 package gorealconf
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrRolloutInProgress is returned by Rollout.Start while another candidate
+// is being rolled out on the same config
+var ErrRolloutInProgress = errors.New("rollout already in progress")
+
+// ErrNoRollout is returned by rollout controls when no rollout is in
+// progress
+var ErrNoRollout = errors.New("no rollout in progress")
+
+// defaultMinRequests is the number of candidate requests observed before
+// the rollback threshold is enforced
+const defaultMinRequests = 100
+
+// defaultHealthCheckInterval is how often failing health checks are retried
+// before a stage may advance
+const defaultHealthCheckInterval = 30 * time.Second
+
+// RolloutStage sends Percentage of traffic to the candidate for Duration
+type RolloutStage struct {
+	Percentage float64       `json:"percentage"`
+	Duration   time.Duration `json:"duration"`
+}
+
+var defaultRolloutStages = []RolloutStage{
+	{Percentage: 1, Duration: 5 * time.Minute},
+	{Percentage: 10, Duration: 10 * time.Minute},
+	{Percentage: 50, Duration: 10 * time.Minute},
+	{Percentage: 100, Duration: 10 * time.Minute},
+}
+
+// Variants served by Rollout.ResolveVariant
+const (
+	VariantStable    = "stable"
+	VariantCandidate = "candidate"
+)
+
+// RolloutState is the lifecycle state of a rollout
+type RolloutState string
+
+const (
+	RolloutIdle       RolloutState = "idle"
+	RolloutInProgress RolloutState = "in_progress"
+	RolloutPromoted   RolloutState = "promoted"
+	RolloutRolledBack RolloutState = "rolled_back"
+	// RolloutStopped means the context passed to Start or Restore was
+	// cancelled. The persisted state is left in progress, so Restore
+	// resumes the rollout after a restart.
+	RolloutStopped RolloutState = "stopped"
+)
+
+// RolloutStatus is a snapshot of a rollout's progress
+type RolloutStatus struct {
+	State           RolloutState  `json:"state"`
+	Stage           int           `json:"stage"` // index of the current stage, -1 before the first
+	Stages          int           `json:"stages"`
+	Percentage      float64       `json:"percentage"`
+	Paused          bool          `json:"paused"`
+	StartedAt       time.Time     `json:"started_at"`
+	StageStartedAt  time.Time     `json:"stage_started_at"`
+	StageElapsed    time.Duration `json:"stage_elapsed"` // time spent in the stage, excluding pauses
+	Requests        uint64        `json:"requests"`      // candidate requests observed
+	Errors          uint64        `json:"errors"`        // candidate errors observed
+	ErrorRate       float64       `json:"error_rate"`
+	StableServed    uint64        `json:"stable_served"`          // requests resolved to the stable value
+	CandidateServed uint64        `json:"candidate_served"`       // requests resolved to the candidate
+	HealthError     string        `json:"health_error,omitempty"` // last failed health check holding the stage
+	Reason          string        `json:"reason,omitempty"`       // why the rollout was rolled back
+}
+
 type Rollout[T any] struct {
-	config    *Config[T]
-	strategy  RolloutStrategy // Changed from Strategy to RolloutStrategy
-	validator func(T) error
-	threshold float64
+	config         *Config[T]
+	strategy       RolloutStrategy // Changed from Strategy to RolloutStrategy
+	validator      func(T) error
+	threshold      float64
+	stages         []RolloutStage
+	minRequests    uint64
+	salt           string
+	healthChecks   []func(ctx context.Context, candidate T) error
+	healthInterval time.Duration
+	store          WritableSource[RolloutRecord[T]]
+	signals        []HealthSignal
+
+	mu           sync.Mutex
+	status       RolloutStatus
+	activeSince  time.Time // when the stage was last resumed, zero while paused
+	candidate    *T
+	candidateRaw T
+	abort        chan string
+	wake         chan struct{}
+	done         chan struct{}
 }
 
 func NewRollout[T any](config *Config[T]) *Rollout[T] {
 	return &Rollout[T]{
-		config: config,
+		config:         config,
+		stages:         defaultRolloutStages,
+		minRequests:    defaultMinRequests,
+		healthInterval: defaultHealthCheckInterval,
+		status:         RolloutStatus{State: RolloutIdle, Stage: -1},
 	}
 }
 
@@ -24,14 +121,525 @@ func (r *Rollout[T]) WithValidation(validator func(T) error) *Rollout[T] {
 	return r
 }
 
+// WithRollbackThreshold rolls the candidate back once its error rate
+// exceeds the stable error rate by more than threshold, a fraction between
+// 0 and 1. Error rates come from health signals, see WithHealthSignal, and
+// from Observe, which compares against a stable error rate of 0.
 func (r *Rollout[T]) WithRollbackThreshold(threshold float64) *Rollout[T] {
 	r.threshold = threshold
 	return r
 }
 
+// WithStages sets the stages a candidate moves through before it is
+// promoted. The default is 1%, 10%, 50% and 100% over 35 minutes.
+func (r *Rollout[T]) WithStages(stages ...RolloutStage) *Rollout[T] {
+	r.stages = stages
+	return r
+}
+
+// WithMinRequests sets how many candidate requests must be observed before
+// the rollback threshold is enforced
+func (r *Rollout[T]) WithMinRequests(n uint64) *Rollout[T] {
+	r.minRequests = n
+	return r
+}
+
+// WithSalt sets the salt used to bucket subjects in ShouldApplyFor
+func (r *Rollout[T]) WithSalt(salt string) *Rollout[T] {
+	r.salt = salt
+	return r
+}
+
+// WithHealthCheck adds a check that must pass before the rollout advances
+// past a stage. While it fails the stage is held and the check retried,
+// see WithHealthCheckInterval.
+func (r *Rollout[T]) WithHealthCheck(check func(ctx context.Context, candidate T) error) *Rollout[T] {
+	r.healthChecks = append(r.healthChecks, check)
+	return r
+}
+
+// WithHealthCheckInterval sets how often failing health checks are
+// retried. The default is 30 seconds.
+func (r *Rollout[T]) WithHealthCheckInterval(interval time.Duration) *Rollout[T] {
+	r.healthInterval = interval
+	return r
+}
+
+// Start validates candidate and begins moving traffic to it through the
+// configured stages. A stage advances once its duration has passed and the
+// health checks pass. After the last stage the candidate is applied to the
+// config; if its error rate exceeds the rollback threshold first, it is
+// dropped and the stable value stays in place. Cancelling ctx stops the
+// rollout, see RolloutStopped.
+func (r *Rollout[T]) Start(ctx context.Context, candidate T) error {
+	value, err := r.config.prepare(ctx, candidate)
+	if err != nil {
+		return err
+	}
+
+	stable := r.config.Get(ctx)
+	if err := r.validate(stable, value); err != nil {
+		return &ValidationError{
+			Message: err.Error(),
+			Old:     Redacted(stable),
+			New:     Redacted(value),
+			Cause:   err,
+		}
+	}
+
+	status := RolloutStatus{
+		State:     RolloutInProgress,
+		Stage:     -1,
+		Stages:    len(r.stages),
+		StartedAt: time.Now(),
+	}
+	return r.begin(ctx, candidate, value, status, 0)
+}
+
+// begin installs a candidate and runs the stages from status.Stage, or
+// from the first stage when status.Stage is -1
+func (r *Rollout[T]) begin(ctx context.Context, raw, value T, status RolloutStatus, elapsed time.Duration) error {
+	r.mu.Lock()
+	// GetFor serves one rollout at a time, cleared again by finish
+	if r.status.State == RolloutInProgress || !r.config.rollout.CompareAndSwap(nil, r) {
+		r.mu.Unlock()
+		return ErrRolloutInProgress
+	}
+	r.candidate = &value
+	r.candidateRaw = raw
+	r.abort = make(chan string, 1)
+	r.wake = make(chan struct{}, 1)
+	r.done = make(chan struct{})
+	r.status = status
+	r.status.StageElapsed = elapsed
+	abort, done := r.abort, r.done
+	r.mu.Unlock()
+
+	go r.run(ctx, abort, done)
+	if len(r.signals) > 0 && r.threshold > 0 {
+		go r.monitor(ctx, abort, done)
+	}
+	return nil
+}
+
+func (r *Rollout[T]) validate(stable, candidate T) error {
+	if r.validator != nil {
+		if err := r.validator(candidate); err != nil {
+			return err
+		}
+	}
+	if r.config.validator != nil {
+		return r.config.validator(stable, candidate)
+	}
+	return nil
+}
+
+func (r *Rollout[T]) run(ctx context.Context, abort <-chan string, done chan struct{}) {
+	defer close(done)
+
+	r.mu.Lock()
+	first := r.status.Stage
+	r.mu.Unlock()
+	resuming := first >= 0
+	if first < 0 {
+		first = 0
+	}
+
+	for i := first; i < len(r.stages); i++ {
+		stage := r.stages[i]
+		r.enterStage(i, stage, resuming)
+		resuming = false
+		r.persist(ctx)
+
+		if reason, ok := r.waitStage(ctx, stage.Duration, abort); !ok {
+			r.stop(ctx, reason)
+			return
+		}
+		if reason, ok := r.gate(ctx, abort); !ok {
+			r.stop(ctx, reason)
+			return
+		}
+	}
+
+	select {
+	case reason := <-abort:
+		r.finish(ctx, RolloutRolledBack, reason)
+		return
+	default:
+	}
+
+	r.mu.Lock()
+	candidate := r.candidateRaw
+	r.mu.Unlock()
+
+	if err := r.config.update(ctx, candidate, nil, SourceRollout, 0); err != nil {
+		r.finish(ctx, RolloutRolledBack, fmt.Sprintf("promotion failed: %v", err))
+		return
+	}
+	r.finish(ctx, RolloutPromoted, "")
+}
+
+// enterStage makes stage i current. A resumed stage keeps its start time
+// and the elapsed time restored with it.
+func (r *Rollout[T]) enterStage(i int, stage RolloutStage, resuming bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.status.Stage = i
+	r.status.Percentage = stage.Percentage
+	if !resuming {
+		r.status.StageStartedAt = now
+		r.status.StageElapsed = 0
+	}
+	if !r.status.Paused {
+		r.activeSince = now
+	}
+	r.config.metrics.SetRolloutStage(i, stage.Percentage)
+	r.config.log(SourceRollout).Info("rollout stage entered",
+		LogKeyVersion, r.config.Version(),
+		"stage", i,
+		"percentage", stage.Percentage,
+		"resumed", resuming,
+	)
+}
+
+// stageElapsed returns the time spent in the current stage excluding
+// pauses. The caller must hold r.mu.
+func (r *Rollout[T]) stageElapsed() time.Duration {
+	elapsed := r.status.StageElapsed
+	if !r.activeSince.IsZero() {
+		elapsed += time.Since(r.activeSince)
+	}
+	return elapsed
+}
+
+// waitStage blocks until the current stage has been active for d, not
+// counting pauses. It returns false with a reason when the rollout is
+// aborted or ctx is cancelled.
+func (r *Rollout[T]) waitStage(ctx context.Context, d time.Duration, abort <-chan string) (string, bool) {
+	for {
+		r.mu.Lock()
+		paused, remaining, wake := r.status.Paused, d-r.stageElapsed(), r.wake
+		r.mu.Unlock()
+
+		if !paused && remaining <= 0 {
+			return "", true
+		}
+
+		// A nil channel blocks, so a paused stage only wakes on a control
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if !paused {
+			timer = time.NewTimer(remaining)
+			timeout = timer.C
+		}
+
+		reason, ok, woken := "", true, false
+		select {
+		case <-ctx.Done():
+			reason, ok = ctx.Err().Error(), false
+		case reason = <-abort:
+			ok = false
+		case <-wake:
+			woken = true
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if !woken {
+			return reason, ok
+		}
+	}
+}
+
+// gate holds the current stage until every health check passes
+func (r *Rollout[T]) gate(ctx context.Context, abort <-chan string) (string, bool) {
+	for {
+		r.mu.Lock()
+		candidate := *r.candidate
+		r.mu.Unlock()
+
+		var failure string
+		if r.config.frozen() {
+			failure = ErrConfigFrozen.Error()
+		}
+		for _, check := range r.healthChecks {
+			if failure != "" {
+				break
+			}
+			if err := check(ctx, candidate); err != nil {
+				failure = err.Error()
+				break
+			}
+		}
+
+		r.mu.Lock()
+		r.status.HealthError = failure
+		paused := r.status.Paused
+		r.mu.Unlock()
+
+		if failure == "" && !paused {
+			return "", true
+		}
+
+		r.mu.Lock()
+		wait := r.stageElapsed() + r.healthInterval
+		r.mu.Unlock()
+		if reason, ok := r.waitStage(ctx, wait, abort); !ok {
+			return reason, false
+		}
+	}
+}
+
+// stop ends the run loop after an abort or a cancelled context
+func (r *Rollout[T]) stop(ctx context.Context, reason string) {
+	if ctx.Err() != nil {
+		r.finish(ctx, RolloutStopped, reason)
+		return
+	}
+	r.finish(ctx, RolloutRolledBack, reason)
+}
+
+func (r *Rollout[T]) finish(ctx context.Context, state RolloutState, reason string) {
+	r.mu.Lock()
+	var zero T
+	candidate := r.candidateRaw
+	r.candidate = nil
+	r.candidateRaw = zero
+	r.status.StageElapsed = r.stageElapsed()
+	r.activeSince = time.Time{}
+	r.status.State = state
+	r.status.Reason = reason
+	if state != RolloutPromoted {
+		r.status.Percentage = 0
+	}
+	r.mu.Unlock()
+
+	r.config.rollout.CompareAndSwap(r, nil)
+	r.config.metrics.SetRolloutStage(-1, 0)
+	logger := r.config.log(SourceRollout).With(LogKeyVersion, r.config.Version())
+	switch state {
+	case RolloutRolledBack:
+		r.config.metrics.IncRollbackCount()
+		logger.Warn("rollout rolled back", "reason", reason)
+
+		stable, version := r.config.snapshot()
+		r.config.audit(ctx, AuditEvent{
+			Source:  SourceRollout,
+			Outcome: AuditRolledBack,
+			Version: version,
+			Reason:  reason,
+		}, stable, candidate)
+	case RolloutStopped:
+		logger.Info("rollout stopped", "reason", reason)
+	case RolloutPromoted:
+		logger.Info("rollout promoted")
+	}
+
+	if state != RolloutStopped {
+		r.persist(context.WithoutCancel(ctx))
+	}
+}
+
+// Pause holds the rollout at its current stage. Traffic keeps being split
+// at the stage's percentage, but the stage does not advance until Resume.
+func (r *Rollout[T]) Pause(ctx context.Context) error {
+	return r.setPaused(ctx, true)
+}
+
+// Resume continues a paused rollout
+func (r *Rollout[T]) Resume(ctx context.Context) error {
+	return r.setPaused(ctx, false)
+}
+
+func (r *Rollout[T]) setPaused(ctx context.Context, paused bool) error {
+	r.mu.Lock()
+	if r.status.State != RolloutInProgress {
+		r.mu.Unlock()
+		return ErrNoRollout
+	}
+	if r.status.Paused == paused {
+		r.mu.Unlock()
+		return nil
+	}
+	if paused {
+		r.status.StageElapsed = r.stageElapsed()
+		r.activeSince = time.Time{}
+	} else {
+		r.activeSince = time.Now()
+	}
+	r.status.Paused = paused
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	r.mu.Unlock()
+
+	return r.persist(ctx)
+}
+
+// Abort rolls the candidate back, keeping the stable value
+func (r *Rollout[T]) Abort(reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status.State != RolloutInProgress {
+		return ErrNoRollout
+	}
+	select {
+	case r.abort <- "aborted: " + reason:
+	default:
+	}
+	return nil
+}
+
+// Observe records the outcome of a request served with the candidate.
+// Pass a nil error for a successful request.
+func (r *Rollout[T]) Observe(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status.State != RolloutInProgress {
+		return
+	}
+
+	r.status.Requests++
+	if err != nil {
+		r.status.Errors++
+	}
+
+	if r.threshold <= 0 || r.status.Requests < r.minRequests {
+		return
+	}
+	if rate := r.status.errorRate(); rate > r.threshold {
+		select {
+		case r.abort <- fmt.Sprintf("error rate %.4f exceeded threshold %.4f", rate, r.threshold):
+		default:
+		}
+	}
+}
+
+func (s RolloutStatus) errorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// Status returns the current progress of the rollout
+func (r *Rollout[T]) Status() RolloutStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.ErrorRate = status.errorRate()
+	status.StageElapsed = r.stageElapsed()
+	return status
+}
+
+// Candidate returns the value being rolled out, if any
+func (r *Rollout[T]) Candidate() (T, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.candidate == nil {
+		var zero T
+		return zero, false
+	}
+	return *r.candidate, true
+}
+
+// Done returns a channel that is closed when the current rollout is
+// promoted, rolled back or stopped. It is nil before the first Start.
+func (r *Rollout[T]) Done() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
+// ShouldApply reports whether the candidate should be used. While a
+// rollout is in progress it is gated by the strategy and the current
+// stage's percentage; otherwise only the strategy decides.
 func (r *Rollout[T]) ShouldApply() bool {
-	if r.strategy == nil {
+	if r.strategy != nil && !r.strategy.ShouldApply() {
+		return false
+	}
+
+	r.mu.Lock()
+	state, percentage := r.status.State, r.status.Percentage
+	r.mu.Unlock()
+
+	if state != RolloutInProgress {
 		return true
 	}
-	return r.strategy.ShouldApply()
+	return rand.Float64()*100 < percentage
+}
+
+// ShouldApplyFor is like ShouldApply but assigns key, such as a user or
+// tenant ID, to a stable bucket. A subject that gets the candidate keeps
+// getting it as the rollout moves to later stages.
+func (r *Rollout[T]) ShouldApplyFor(key string) bool {
+	return r.ShouldApplyTo(Subject{ID: key})
+}
+
+// ShouldApplyTo is like ShouldApplyFor but also passes the subject's
+// attributes to strategies that target them, such as RuleStrategy
+func (r *Rollout[T]) ShouldApplyTo(subject Subject) bool {
+	if r.strategy != nil && !applyTo(r.strategy, subject) {
+		return false
+	}
+
+	r.mu.Lock()
+	state, percentage := r.status.State, r.status.Percentage
+	r.mu.Unlock()
+
+	if state != RolloutInProgress {
+		return true
+	}
+	return inPercentage(r.salt, subject.ID, percentage)
+}
+
+// Resolve returns the value to serve for the subject in ctx, see
+// ResolveVariant
+func (r *Rollout[T]) Resolve(ctx context.Context) T {
+	value, _ := r.ResolveVariant(ctx)
+	return value
+}
+
+// ResolveVariant returns the candidate when the subject in ctx falls in the
+// current stage and the stable value otherwise, together with the variant
+// served. Requests without a subject get the stable value, so that a
+// subject never flips between variants.
+func (r *Rollout[T]) ResolveVariant(ctx context.Context) (T, string) {
+	variant := VariantStable
+	if subject, ok := SubjectFromContext(ctx); ok {
+		r.mu.Lock()
+		inProgress := r.status.State == RolloutInProgress
+		r.mu.Unlock()
+
+		if inProgress && r.ShouldApplyTo(subject) {
+			variant = VariantCandidate
+		}
+	}
+
+	r.mu.Lock()
+	candidate := r.candidate
+	if variant == VariantCandidate && candidate == nil {
+		// The rollout finished since the decision was made
+		variant = VariantStable
+	}
+	if variant == VariantCandidate {
+		r.status.CandidateServed++
+	} else {
+		r.status.StableServed++
+	}
+	r.mu.Unlock()
+
+	r.config.metrics.IncVariantServed(variant)
+	if variant == VariantCandidate {
+		return *candidate, variant
+	}
+	return r.config.Get(ctx), variant
 }
@@ -0,0 +1,86 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// RolloutRecord is the persisted state of a rollout, see
+// Rollout.WithStateStore
+type RolloutRecord[T any] struct {
+	Candidate T             `json:"candidate"`
+	Status    RolloutStatus `json:"status"`
+	// ActiveSince is when the current stage was last resumed. It is zero
+	// while the rollout is paused.
+	ActiveSince time.Time `json:"active_since,omitempty"`
+}
+
+// WithStateStore persists the rollout's state to store whenever it changes
+// stage, is paused, resumed or finishes, so that Restore can resume it
+// after a restart. The candidate is stored as it was passed to Start,
+// before decryption and secret resolution.
+func (r *Rollout[T]) WithStateStore(store WritableSource[RolloutRecord[T]]) *Rollout[T] {
+	r.store = store
+	return r
+}
+
+// persist saves the current state when a store is configured
+func (r *Rollout[T]) persist(ctx context.Context) error {
+	if r.store == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	record := RolloutRecord[T]{
+		Candidate:   r.candidateRaw,
+		Status:      r.status,
+		ActiveSince: r.activeSince,
+	}
+	record.Status.ErrorRate = record.Status.errorRate()
+	r.mu.Unlock()
+
+	return r.store.Save(ctx, record)
+}
+
+// Restore resumes a rollout that was in progress when its state was last
+// persisted, at the stage and elapsed time it had reached. Time the
+// process was down counts toward the stage unless the rollout was paused.
+// It reports whether a rollout was resumed.
+func (r *Rollout[T]) Restore(ctx context.Context) (bool, error) {
+	if r.store == nil {
+		return false, errors.New("rollout has no state store")
+	}
+
+	record, err := r.store.Load(ctx)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if record.Status.State != RolloutInProgress {
+		return false, nil
+	}
+
+	value, err := r.config.prepare(ctx, record.Candidate)
+	if err != nil {
+		return false, err
+	}
+
+	status := record.Status
+	status.Stages = len(r.stages)
+	if status.Stage >= len(r.stages) {
+		status.Stage = len(r.stages) - 1
+	}
+
+	elapsed := status.StageElapsed
+	if !status.Paused && !record.ActiveSince.IsZero() {
+		elapsed += time.Since(record.ActiveSince)
+	}
+	if err := r.begin(ctx, record.Candidate, value, status, elapsed); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,162 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRolloutSchedule(t *testing.T) {
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+
+	waitFor := func(t *testing.T, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("timeout waiting for condition")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	t.Run("health gate", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var healthy atomic.Bool
+		cfg := New[TestConfig]()
+		rollout := NewRollout(cfg).
+			WithStages(
+				RolloutStage{Percentage: 10, Duration: 10 * time.Millisecond},
+				RolloutStage{Percentage: 100, Duration: 10 * time.Millisecond},
+			).
+			WithHealthCheck(func(ctx context.Context, candidate TestConfig) error {
+				if !healthy.Load() {
+					return errors.New("p99 latency too high")
+				}
+				return nil
+			}).
+			WithHealthCheckInterval(10 * time.Millisecond)
+
+		if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		waitFor(t, func() bool { return rollout.Status().HealthError != "" })
+		time.Sleep(50 * time.Millisecond)
+		if status := rollout.Status(); status.Stage != 0 {
+			t.Fatalf("expected stage to be held while unhealthy, got %+v", status)
+		}
+
+		healthy.Store(true)
+		waitFor(t, func() bool { return rollout.Status().State == RolloutPromoted })
+		if got := cfg.Get(ctx).Value; got != "candidate" {
+			t.Errorf("expected candidate to be promoted, got %q", got)
+		}
+	})
+
+	t.Run("pause resume abort", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := New[TestConfig]()
+		cfg.Update(ctx, TestConfig{Value: "stable"})
+		rollout := NewRollout(cfg).WithStages(
+			RolloutStage{Percentage: 10, Duration: 50 * time.Millisecond},
+			RolloutStage{Percentage: 50, Duration: time.Hour},
+		)
+
+		if err := rollout.Pause(ctx); !errors.Is(err, ErrNoRollout) {
+			t.Errorf("expected ErrNoRollout, got %v", err)
+		}
+		if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := rollout.Pause(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		status := rollout.Status()
+		if !status.Paused || status.Stage != 0 || status.StageElapsed >= 50*time.Millisecond {
+			t.Fatalf("expected paused first stage, got %+v", status)
+		}
+
+		if err := rollout.Resume(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		waitFor(t, func() bool { return rollout.Status().Stage == 1 })
+
+		if err := rollout.Abort("bad metrics"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		waitFor(t, func() bool { return rollout.Status().State == RolloutRolledBack })
+		if got := cfg.Get(ctx).Value; got != "stable" {
+			t.Errorf("expected stable value after abort, got %q", got)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		store, err := NewFileSource[RolloutRecord[TestConfig]](filepath.Join(t.TempDir(), "rollout.json"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stages := []RolloutStage{
+			{Percentage: 10, Duration: 10 * time.Millisecond},
+			{Percentage: 50, Duration: time.Hour},
+			{Percentage: 100, Duration: time.Hour},
+		}
+
+		// Nothing to restore yet
+		if ok, err := NewRollout(New[TestConfig]()).WithStateStore(store).Restore(context.Background()); ok || err != nil {
+			t.Fatalf("expected nothing to restore, got %v, %v", ok, err)
+		}
+
+		ctx, stop := context.WithCancel(context.Background())
+		first := NewRollout(New[TestConfig]()).WithStages(stages...).WithStateStore(store)
+		if err := first.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		waitFor(t, func() bool { return first.Status().Stage == 1 })
+		first.Pause(ctx)
+
+		// Simulate a restart
+		stop()
+		<-first.Done()
+		if status := first.Status(); status.State != RolloutStopped {
+			t.Fatalf("expected stopped, got %+v", status)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := New[TestConfig]()
+		second := NewRollout(cfg).WithStages(stages...).WithStateStore(store)
+		ok, err := second.Restore(ctx)
+		if err != nil || !ok {
+			t.Fatalf("expected rollout to be restored, got %v, %v", ok, err)
+		}
+
+		status := second.Status()
+		if status.State != RolloutInProgress || status.Stage != 1 || !status.Paused {
+			t.Errorf("expected paused second stage, got %+v", status)
+		}
+		if candidate, _ := second.Candidate(); candidate.Value != "candidate" {
+			t.Errorf("expected restored candidate, got %+v", candidate)
+		}
+
+		if err := second.Abort("test"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		<-second.Done()
+		if ok, _ := NewRollout(cfg).WithStateStore(store).Restore(ctx); ok {
+			t.Error("expected finished rollout not to be restored")
+		}
+	})
+}
@@ -1,7 +1,11 @@
 package gorealconf
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
@@ -9,6 +13,38 @@ type RolloutStrategy interface {
 	ShouldApply() bool
 }
 
+// KeyedStrategy is a RolloutStrategy that can decide per subject, such as
+// a user, tenant or host, so the same key always gets the same answer
+type KeyedStrategy interface {
+	RolloutStrategy
+	ShouldApplyFor(key string) bool
+}
+
+// bucketCount is the resolution of percentage bucketing, 0.01%
+const bucketCount = 10000
+
+// bucket assigns key to one of bucketCount buckets. The assignment depends
+// only on salt and key, so it is stable across calls and processes.
+func bucket(salt, key string) uint64 {
+	sum := sha256.Sum256([]byte(salt + ":" + key))
+	return binary.BigEndian.Uint64(sum[:8]) % bucketCount
+}
+
+// inPercentage reports whether key falls within the first percentage of
+// buckets. Raising the percentage only ever adds buckets.
+func inPercentage(salt, key string, percentage float64) bool {
+	return float64(bucket(salt, key)) < percentage*bucketCount/100
+}
+
+// applyFor asks s about key, falling back to ShouldApply for strategies
+// that are not keyed
+func applyFor(s RolloutStrategy, key string) bool {
+	if keyed, ok := s.(KeyedStrategy); ok {
+		return keyed.ShouldApplyFor(key)
+	}
+	return s.ShouldApply()
+}
+
 // Only one definition of CompositeStrategy
 type CompositeStrategy struct {
 	strategies []RolloutStrategy
@@ -34,32 +70,92 @@ func (cs *CompositeStrategy) ShouldApply() bool {
 	return true
 }
 
+func (cs *CompositeStrategy) ShouldApplyFor(key string) bool {
+	for _, s := range cs.strategies {
+		if !applyFor(s, key) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cs *CompositeStrategy) ShouldApplyTo(subject Subject) bool {
+	for _, s := range cs.strategies {
+		if !applyTo(s, subject) {
+			return false
+		}
+	}
+	return true
+}
+
 type PercentageStrategy struct {
-	percentage float64
+	percentage atomic.Uint64 // math.Float64bits of the percentage
+	salt       string
 }
 
 func NewPercentageStrategy(percentage float64) *PercentageStrategy {
-	return &PercentageStrategy{
-		percentage: percentage,
-	}
+	s := &PercentageStrategy{}
+	s.SetPercentage(percentage)
+	return s
+}
+
+// WithSalt sets the salt mixed into bucket assignment. Use a different
+// salt per rollout so the same subjects are not always first.
+func (s *PercentageStrategy) WithSalt(salt string) *PercentageStrategy {
+	s.salt = salt
+	return s
+}
+
+// SetPercentage changes the share of subjects the strategy applies to
+func (s *PercentageStrategy) SetPercentage(percentage float64) {
+	s.percentage.Store(math.Float64bits(percentage))
+}
+
+func (s *PercentageStrategy) Percentage() float64 {
+	return math.Float64frombits(s.percentage.Load())
 }
 
+// ShouldApply makes a random decision on every call; prefer ShouldApplyFor
+// when requests can be attributed to a subject
 func (s *PercentageStrategy) ShouldApply() bool {
-	return rand.Float64()*100 <= s.percentage
+	return rand.Float64()*100 <= s.Percentage()
+}
+
+// ShouldApplyFor assigns key to a stable bucket, so a subject keeps its
+// answer across calls and raising the percentage never moves a subject
+// out of the rollout
+func (s *PercentageStrategy) ShouldApplyFor(key string) bool {
+	return inPercentage(s.salt, key, s.Percentage())
 }
 
+// TimeBasedStrategy applies once duration has passed since it was
+// created. The start is not persisted, so it resets when the process
+// restarts; see AbsoluteTimeStrategy for a fixed start.
 type TimeBasedStrategy struct {
 	startTime time.Time
 	duration  time.Duration
+	clock     Clock
 }
 
 func NewTimeBasedStrategy(duration time.Duration) *TimeBasedStrategy {
 	return &TimeBasedStrategy{
-		startTime: time.Now(),
+		startTime: systemClock.Now(),
 		duration:  duration,
+		clock:     systemClock,
 	}
 }
 
+// WithClock sets the clock and restarts the duration from its current time
+func (s *TimeBasedStrategy) WithClock(clock Clock) *TimeBasedStrategy {
+	s.clock = clock
+	s.startTime = clock.Now()
+	return s
+}
+
 func (s *TimeBasedStrategy) ShouldApply() bool {
-	return time.Since(s.startTime) >= s.duration
+	return s.clock.Now().Sub(s.startTime) >= s.duration
+}
+
+func (s *TimeBasedStrategy) ShouldApplyFor(key string) bool {
+	return s.ShouldApply()
 }
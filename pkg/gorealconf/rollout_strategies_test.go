@@ -0,0 +1,131 @@
+package gorealconf
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPercentageStrategyFor(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user-%d", i)
+	}
+
+	applied := func(s KeyedStrategy) map[string]bool {
+		result := make(map[string]bool)
+		for _, key := range keys {
+			if s.ShouldApplyFor(key) {
+				result[key] = true
+			}
+		}
+		return result
+	}
+
+	t.Run("sticky", func(t *testing.T) {
+		strategy := NewPercentageStrategy(50).WithSalt("checkout")
+		for _, key := range keys[:100] {
+			want := strategy.ShouldApplyFor(key)
+			for i := 0; i < 5; i++ {
+				if strategy.ShouldApplyFor(key) != want {
+					t.Fatalf("assignment for %s changed between calls", key)
+				}
+			}
+		}
+	})
+
+	t.Run("distribution", func(t *testing.T) {
+		tests := []float64{0, 1, 25, 50, 100}
+		for _, percentage := range tests {
+			got := float64(len(applied(NewPercentageStrategy(percentage)))) / float64(len(keys)) * 100
+			if got < percentage-2 || got > percentage+2 {
+				t.Errorf("percentage %v: applied to %.2f%% of keys", percentage, got)
+			}
+		}
+	})
+
+	t.Run("raising only adds buckets", func(t *testing.T) {
+		strategy := NewPercentageStrategy(10).WithSalt("checkout")
+		before := applied(strategy)
+
+		strategy.SetPercentage(30)
+		after := applied(strategy)
+
+		for key := range before {
+			if !after[key] {
+				t.Fatalf("%s left the rollout when the percentage was raised", key)
+			}
+		}
+		if len(after) <= len(before) {
+			t.Errorf("expected more keys at 30%%, got %d then %d", len(before), len(after))
+		}
+	})
+
+	t.Run("salt", func(t *testing.T) {
+		a := applied(NewPercentageStrategy(10).WithSalt("a"))
+		b := applied(NewPercentageStrategy(10).WithSalt("b"))
+
+		same := 0
+		for key := range a {
+			if b[key] {
+				same++
+			}
+		}
+		if same > len(a)/2 {
+			t.Errorf("expected salts to select different keys, %d of %d shared", same, len(a))
+		}
+	})
+
+	t.Run("composite", func(t *testing.T) {
+		strategy := NewCompositeStrategy().
+			Add(NewPercentageStrategy(50)).
+			Add(NewTimeBasedStrategy(0))
+		want := applied(NewPercentageStrategy(50))
+		if got := applied(strategy); len(got) != len(want) {
+			t.Errorf("expected %d keys, got %d", len(want), len(got))
+		}
+	})
+}
+
+func TestRolloutShouldApplyFor(t *testing.T) {
+	type TestConfig struct {
+		Value string
+	}
+
+	cfg := New[TestConfig]()
+	rollout := NewRollout(cfg).
+		WithSalt("test").
+		WithStages(
+			RolloutStage{Percentage: 10, Duration: 50 * time.Millisecond},
+			RolloutStage{Percentage: 60, Duration: time.Hour},
+		)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	var early []string
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		if rollout.ShouldApplyFor(key) {
+			early = append(early, key)
+		}
+	}
+	if len(early) == 0 || len(early) > 200 {
+		t.Fatalf("expected about 10%% of keys in the first stage, got %d", len(early))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for rollout.Status().Stage != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, key := range early {
+		if !rollout.ShouldApplyFor(key) {
+			t.Fatalf("%s lost the candidate in a later stage", key)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRollout(t *testing.T) {
+	type TestConfig struct {
+		Value string
+	}
+
+	waitDone := func(t *testing.T, rollout *Rollout[TestConfig]) {
+		t.Helper()
+		select {
+		case <-rollout.Done():
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for rollout to finish")
+		}
+	}
+
+	t.Run("promote", func(t *testing.T) {
+		ctx := context.Background()
+		cfg := New[TestConfig]()
+		cfg.Update(ctx, TestConfig{Value: "stable"})
+
+		rollout := NewRollout(cfg).WithStages(
+			RolloutStage{Percentage: 0, Duration: 50 * time.Millisecond},
+			RolloutStage{Percentage: 100, Duration: 10 * time.Millisecond},
+		)
+		if status := rollout.Status(); status.State != RolloutIdle {
+			t.Errorf("expected idle, got %s", status.State)
+		}
+
+		if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := rollout.Start(ctx, TestConfig{Value: "other"}); !errors.Is(err, ErrRolloutInProgress) {
+			t.Errorf("expected ErrRolloutInProgress, got %v", err)
+		}
+		if err := NewRollout(cfg).Start(ctx, TestConfig{Value: "other"}); !errors.Is(err, ErrRolloutInProgress) {
+			t.Errorf("expected ErrRolloutInProgress for a second rollout of the config, got %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		if status := rollout.Status(); status.State != RolloutInProgress || status.Stage != 0 {
+			t.Errorf("expected first stage in progress, got %+v", status)
+		}
+		if rollout.ShouldApply() {
+			t.Error("expected no traffic at 0%")
+		}
+		if candidate, ok := rollout.Candidate(); !ok || candidate.Value != "candidate" {
+			t.Errorf("expected candidate, got %+v", candidate)
+		}
+		if got := cfg.Get(ctx).Value; got != "stable" {
+			t.Errorf("expected stable value during rollout, got %q", got)
+		}
+
+		waitDone(t, rollout)
+
+		status := rollout.Status()
+		if status.State != RolloutPromoted || status.Stage != 1 {
+			t.Errorf("expected promoted after last stage, got %+v", status)
+		}
+		if got := cfg.Get(ctx).Value; got != "candidate" {
+			t.Errorf("expected candidate to be promoted, got %q", got)
+		}
+		if cfg.rollout.Load() != nil {
+			t.Error("expected GetFor to stop serving through the finished rollout")
+		}
+	})
+
+	t.Run("rollback on error rate", func(t *testing.T) {
+		ctx := context.Background()
+		cfg := New[TestConfig]()
+		cfg.Update(ctx, TestConfig{Value: "stable"})
+
+		rollout := NewRollout(cfg).
+			WithStages(RolloutStage{Percentage: 100, Duration: time.Hour}).
+			WithRollbackThreshold(0.1).
+			WithMinRequests(10)
+		if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// 1 error in 9 requests stays below the minimum sample size
+		rollout.Observe(errors.New("failed"))
+		for i := 0; i < 8; i++ {
+			rollout.Observe(nil)
+		}
+		if status := rollout.Status(); status.State != RolloutInProgress {
+			t.Fatalf("expected rollout in progress, got %+v", status)
+		}
+
+		rollout.Observe(errors.New("failed"))
+		waitDone(t, rollout)
+
+		status := rollout.Status()
+		if status.State != RolloutRolledBack || status.Reason == "" {
+			t.Errorf("expected rollback with reason, got %+v", status)
+		}
+		if status.ErrorRate != 0.2 {
+			t.Errorf("expected error rate 0.2, got %v", status.ErrorRate)
+		}
+		if _, ok := rollout.Candidate(); ok {
+			t.Error("expected candidate to be dropped")
+		}
+		if got := cfg.Get(ctx).Value; got != "stable" {
+			t.Errorf("expected stable value to be kept, got %q", got)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cfg := New[TestConfig]()
+
+		rollout := NewRollout(cfg).WithStages(RolloutStage{Percentage: 100, Duration: time.Hour})
+		if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cancel()
+		waitDone(t, rollout)
+
+		if status := rollout.Status(); status.State != RolloutStopped {
+			t.Errorf("expected stopped, got %+v", status)
+		}
+	})
+
+	t.Run("invalid candidate", func(t *testing.T) {
+		cfg := New[TestConfig]()
+		rollout := NewRollout(cfg).WithValidation(func(c TestConfig) error {
+			if c.Value == "" {
+				return errors.New("value required")
+			}
+			return nil
+		})
+
+		var validationErr *ValidationError
+		if err := rollout.Start(context.Background(), TestConfig{}); !errors.As(err, &validationErr) {
+			t.Errorf("expected *ValidationError, got %v", err)
+		}
+		if status := rollout.Status(); status.State != RolloutIdle {
+			t.Errorf("expected idle, got %s", status.State)
+		}
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		metrics := NewMetrics("test")
+		cfg := New[TestConfig](WithMetrics[TestConfig](metrics))
+		cfg.Update(ctx, TestConfig{Value: "stable"})
+
+		rollout := NewRollout(cfg).WithStages(RolloutStage{Percentage: 50, Duration: time.Hour})
+		if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if got := cfg.GetFor(ctx).Value; got != "stable" {
+			t.Errorf("expected stable value without a subject, got %q", got)
+		}
+
+		served := map[string]int{}
+		for i := 0; i < 200; i++ {
+			subjectCtx := WithSubject(ctx, Subject{ID: fmt.Sprintf("user-%d", i)})
+			value, variant := rollout.ResolveVariant(subjectCtx)
+			if value.Value != variant {
+				t.Fatalf("variant %s served value %q", variant, value.Value)
+			}
+			if got := cfg.GetFor(subjectCtx).Value; got != value.Value {
+				t.Fatalf("GetFor served %q, Resolve served %q", got, value.Value)
+			}
+			served[variant]++
+		}
+		if served[VariantCandidate] == 0 || served[VariantStable] == 0 {
+			t.Errorf("expected both variants to be served, got %v", served)
+		}
+
+		status := rollout.Status()
+		if status.CandidateServed != 2*uint64(served[VariantCandidate]) {
+			t.Errorf("expected %d candidate requests, got %d", 2*served[VariantCandidate], status.CandidateServed)
+		}
+		if got := testutil.ToFloat64(metrics.variantsServed.WithLabelValues(VariantCandidate)); got != float64(status.CandidateServed) {
+			t.Errorf("expected candidate metric %d, got %v", status.CandidateServed, got)
+		}
+	})
+}
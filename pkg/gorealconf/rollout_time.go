@@ -0,0 +1,212 @@
+package gorealconf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock tells time-based strategies the current time, so they can be
+// tested without waiting
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// systemClock is the default Clock
+var systemClock Clock = ClockFunc(time.Now)
+
+// ErrConfigFrozen is returned by Config.Update during a freeze period, see
+// WithFreeze
+var ErrConfigFrozen = errors.New("configuration changes are frozen")
+
+// AbsoluteTimeStrategy applies from a fixed point in time, optionally until
+// another. Unlike TimeBasedStrategy it does not depend on when the process
+// started.
+type AbsoluteTimeStrategy struct {
+	start time.Time
+	end   time.Time
+	clock Clock
+}
+
+// NewAbsoluteTimeStrategy applies from start onwards
+func NewAbsoluteTimeStrategy(start time.Time) *AbsoluteTimeStrategy {
+	return &AbsoluteTimeStrategy{
+		start: start,
+		clock: systemClock,
+	}
+}
+
+// Until stops the strategy from applying at end
+func (s *AbsoluteTimeStrategy) Until(end time.Time) *AbsoluteTimeStrategy {
+	s.end = end
+	return s
+}
+
+func (s *AbsoluteTimeStrategy) WithClock(clock Clock) *AbsoluteTimeStrategy {
+	s.clock = clock
+	return s
+}
+
+func (s *AbsoluteTimeStrategy) ShouldApply() bool {
+	now := s.clock.Now()
+	return !now.Before(s.start) && (s.end.IsZero() || now.Before(s.end))
+}
+
+func (s *AbsoluteTimeStrategy) ShouldApplyFor(key string) bool {
+	return s.ShouldApply()
+}
+
+// CronWindowStrategy applies during windows that open at every match of a
+// cron expression and stay open for a duration, e.g. "0 9 * * 1-5" for 8
+// hours covers business hours on weekdays
+type CronWindowStrategy struct {
+	schedule *cronSchedule
+	duration time.Duration
+	location *time.Location
+	clock    Clock
+
+	mu         sync.Mutex
+	lastMinute time.Time
+	lastResult bool
+}
+
+// NewCronWindowStrategy parses a five field cron expression evaluated in
+// location, or UTC when location is nil
+func NewCronWindowStrategy(expr string, duration time.Duration, location *time.Location) (*CronWindowStrategy, error) {
+	schedule, err := parseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("cron window duration must be positive, got %s", duration)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &CronWindowStrategy{
+		schedule: schedule,
+		duration: duration,
+		location: location,
+		clock:    systemClock,
+	}, nil
+}
+
+func (s *CronWindowStrategy) WithClock(clock Clock) *CronWindowStrategy {
+	s.clock = clock
+	return s
+}
+
+// ShouldApply reports whether a window is open
+func (s *CronWindowStrategy) ShouldApply() bool {
+	return s.openAt(s.clock.Now())
+}
+
+func (s *CronWindowStrategy) ShouldApplyFor(key string) bool {
+	return s.ShouldApply()
+}
+
+// openAt reports whether a window opened within duration before t. The
+// answer only changes once a minute, so it is cached.
+func (s *CronWindowStrategy) openAt(t time.Time) bool {
+	minute := t.Truncate(time.Minute)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if minute.Equal(s.lastMinute) {
+		return s.lastResult
+	}
+
+	open := false
+	for m := minute; m.After(minute.Add(-s.duration)); m = m.Add(-time.Minute) {
+		if s.schedule.matches(m.In(s.location)) {
+			open = true
+			break
+		}
+	}
+
+	s.lastMinute, s.lastResult = minute, open
+	return open
+}
+
+// FreezePeriod is a fixed span of time during which changes are frozen
+type FreezePeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// FreezeStrategy blocks changes during freeze periods and recurring cron
+// windows. As a RolloutStrategy it never applies while frozen; with
+// WithFreeze it rejects config updates and holds rollouts.
+type FreezeStrategy struct {
+	periods []FreezePeriod
+	windows []*CronWindowStrategy
+	clock   Clock
+}
+
+func NewFreezeStrategy(periods ...FreezePeriod) *FreezeStrategy {
+	return &FreezeStrategy{
+		periods: periods,
+		clock:   systemClock,
+	}
+}
+
+// AddWindow freezes changes while window is open, e.g. every Friday from
+// 16:00 for the weekend
+func (s *FreezeStrategy) AddWindow(window *CronWindowStrategy) *FreezeStrategy {
+	s.windows = append(s.windows, window)
+	return s
+}
+
+func (s *FreezeStrategy) WithClock(clock Clock) *FreezeStrategy {
+	s.clock = clock
+	return s
+}
+
+// Frozen reports whether changes are currently frozen
+func (s *FreezeStrategy) Frozen() bool {
+	now := s.clock.Now()
+	for _, p := range s.periods {
+		if !now.Before(p.Start) && now.Before(p.End) {
+			return true
+		}
+	}
+	for _, w := range s.windows {
+		if w.openAt(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FreezeStrategy) ShouldApply() bool {
+	return !s.Frozen()
+}
+
+func (s *FreezeStrategy) ShouldApplyFor(key string) bool {
+	return s.ShouldApply()
+}
+
+// WithFreeze rejects updates with ErrConfigFrozen while freeze is frozen.
+// The initial load from every source and the first update of a Config
+// without sources are always allowed, and rollouts hold their current
+// stage until the freeze ends.
+func WithFreeze[T any](freeze *FreezeStrategy) Option[T] {
+	return func(c *Config[T]) {
+		c.freeze = freeze
+	}
+}
+
+// frozen reports whether updates are currently rejected. Load bypasses it.
+func (c *Config[T]) frozen() bool {
+	return c.freeze != nil && c.Version() > 0 && c.freeze.Frozen()
+}
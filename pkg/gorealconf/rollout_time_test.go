@@ -0,0 +1,186 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time is set by the test
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		time    string
+		want    bool
+		wantErr bool
+	}{
+		{expr: "* * * * *", time: "2026-11-02T10:17:00Z", want: true},
+		{expr: "*/15 * * * *", time: "2026-11-02T10:45:00Z", want: true},
+		{expr: "*/15 * * * *", time: "2026-11-02T10:46:00Z", want: false},
+		{expr: "0 9-17/2 * * *", time: "2026-11-02T11:00:00Z", want: true},
+		{expr: "0 9-17/2 * * *", time: "2026-11-02T12:00:00Z", want: false},
+		{expr: "30 2 1,15 * *", time: "2026-11-15T02:30:00Z", want: true},
+		{expr: "0 0 * 12 *", time: "2026-11-01T00:00:00Z", want: false},
+		{expr: "0 9 * * 1-5", time: "2026-11-06T09:00:00Z", want: true},  // Friday
+		{expr: "0 9 * * 1-5", time: "2026-11-07T09:00:00Z", want: false}, // Saturday
+		{expr: "0 0 * * 7", time: "2026-11-08T00:00:00Z", want: true},    // Sunday
+		{expr: "0 0 13 * 5", time: "2026-11-06T00:00:00Z", want: true},   // either day field
+		{expr: "0 0 */2 * 5", time: "2026-11-06T00:00:00Z", want: false}, // a step is not a restriction
+		{expr: "0 0 13 * */2", time: "2026-11-13T00:00:00Z", want: false},
+		{expr: "0 0 * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.time, func(t *testing.T) {
+			schedule, err := parseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCron() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			at, _ := time.Parse(time.RFC3339, tt.time)
+			if got := schedule.matches(at); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeStrategies(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC)}
+
+	t.Run("absolute", func(t *testing.T) {
+		launch := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		strategy := NewAbsoluteTimeStrategy(launch).Until(launch.Add(24 * time.Hour)).WithClock(clock)
+
+		if strategy.ShouldApply() {
+			t.Error("expected strategy not to apply before start")
+		}
+		clock.now = launch
+		if !strategy.ShouldApply() {
+			t.Error("expected strategy to apply at start")
+		}
+		clock.now = launch.Add(24 * time.Hour)
+		if strategy.ShouldApply() {
+			t.Error("expected strategy not to apply after end")
+		}
+	})
+
+	t.Run("time based", func(t *testing.T) {
+		strategy := NewTimeBasedStrategy(time.Hour).WithClock(clock)
+		if strategy.ShouldApply() {
+			t.Error("expected strategy not to apply yet")
+		}
+		clock.now = clock.now.Add(time.Hour)
+		if !strategy.ShouldApply() {
+			t.Error("expected strategy to apply after duration")
+		}
+	})
+
+	t.Run("cron window", func(t *testing.T) {
+		berlin, err := time.LoadLocation("Europe/Berlin")
+		if err != nil {
+			t.Skip("time zone database not available")
+		}
+		strategy, err := NewCronWindowStrategy("0 9 * * 1-5", 8*time.Hour, berlin)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		strategy.WithClock(clock)
+
+		tests := []struct {
+			name string
+			at   time.Time
+			want bool
+		}{
+			{"before opening", time.Date(2026, 11, 2, 8, 59, 0, 0, berlin), false},
+			{"opening", time.Date(2026, 11, 2, 9, 0, 0, 0, berlin), true},
+			{"afternoon", time.Date(2026, 11, 2, 16, 59, 30, 0, berlin), true},
+			{"closing", time.Date(2026, 11, 2, 17, 0, 0, 0, berlin), false},
+			{"weekend", time.Date(2026, 11, 7, 12, 0, 0, 0, berlin), false},
+			{"utc equivalent", time.Date(2026, 11, 2, 8, 30, 0, 0, time.UTC), true},
+		}
+		for _, tt := range tests {
+			clock.now = tt.at
+			if got := strategy.ShouldApply(); got != tt.want {
+				t.Errorf("%s: ShouldApply() = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("freeze", func(t *testing.T) {
+		type TestConfig struct {
+			Value string
+		}
+
+		clock.now = time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+		weekends, _ := NewCronWindowStrategy("0 16 * * 5", 64*time.Hour, time.UTC)
+		freeze := NewFreezeStrategy(FreezePeriod{
+			Start: time.Date(2026, 12, 21, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC),
+		}).AddWindow(weekends.WithClock(clock)).WithClock(clock)
+
+		ctx := context.Background()
+		cfg := New[TestConfig](WithFreeze[TestConfig](freeze))
+
+		// Sunday, December 20th is in the weekend window, but the initial
+		// value is always accepted
+		if err := cfg.Update(ctx, TestConfig{Value: "initial"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := cfg.Update(ctx, TestConfig{Value: "weekend"}); !errors.Is(err, ErrConfigFrozen) {
+			t.Errorf("expected ErrConfigFrozen on the weekend, got %v", err)
+		}
+
+		clock.now = time.Date(2026, 12, 28, 10, 0, 0, 0, time.UTC)
+		if freeze.ShouldApply() {
+			t.Error("expected no changes during the holiday freeze")
+		}
+
+		// Loading from every source is part of the initial load
+		dir := t.TempDir()
+		var sources []Option[TestConfig]
+		for i, value := range []string{`{"Value":"first"}`, `{"Value":"second"}`} {
+			path := filepath.Join(dir, fmt.Sprintf("config-%d.json", i))
+			if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			source, err := NewFileSource[TestConfig](path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sources = append(sources, WithSource[TestConfig](source))
+		}
+		loadCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		loaded := New[TestConfig](append(sources, WithFreeze[TestConfig](freeze))...)
+		if err := loaded.Load(loadCtx); err != nil {
+			t.Fatalf("expected the initial load to ignore the freeze, got %v", err)
+		}
+		if got := loaded.Get(ctx).Value; got != "second" {
+			t.Errorf("expected the last source's value, got %q", got)
+		}
+
+		clock.now = time.Date(2027, 1, 5, 10, 0, 0, 0, time.UTC)
+		if err := cfg.Update(ctx, TestConfig{Value: "after"}); err != nil {
+			t.Errorf("unexpected error after the freeze: %v", err)
+		}
+	})
+}
@@ -0,0 +1,341 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// Rule operators
+const (
+	OpEquals    = "eq"     // attribute equals Value
+	OpNotEquals = "neq"    // attribute does not equal Value
+	OpIn        = "in"     // attribute is one of Values
+	OpNotIn     = "not_in" // attribute is none of Values
+	OpGlob      = "glob"   // attribute matches one of the path.Match patterns in Values
+	OpSemver    = "semver" // attribute is a version satisfying the constraint in Value
+	OpExists    = "exists" // attribute is set
+)
+
+// subjectIDAttribute refers to Subject.ID in rules
+const subjectIDAttribute = "id"
+
+// Rule targets subjects by their attributes. A rule is either a
+// composition, with exactly one of All, Any or Not set, or a condition on
+// Attribute. The attribute "id" refers to the subject ID.
+//
+// Rules are plain data so they can be loaded from any source:
+//
+//	{"all": [
+//	  {"attribute": "region", "op": "in", "values": ["eu-west-1", "eu-central-1"]},
+//	  {"attribute": "app_version", "op": "semver", "value": ">=1.4.0 <2.0.0"},
+//	  {"not": {"attribute": "tier", "op": "eq", "value": "free"}}
+//	]}
+type Rule struct {
+	All []Rule `json:"all,omitempty"`
+	Any []Rule `json:"any,omitempty"`
+	Not *Rule  `json:"not,omitempty"`
+
+	Attribute string   `json:"attribute,omitempty"`
+	Operator  string   `json:"op,omitempty"`
+	Value     string   `json:"value,omitempty"`
+	Values    []string `json:"values,omitempty"`
+
+	// constraint is Value parsed by Validate for the semver operator
+	constraint versionConstraint
+}
+
+// Validate checks that the rule and all nested rules are well formed. It
+// also prepares semver conditions, so validated rules match faster.
+func (r *Rule) Validate() error {
+	composed := 0
+	if r.All != nil {
+		composed++
+	}
+	if r.Any != nil {
+		composed++
+	}
+	if r.Not != nil {
+		composed++
+	}
+
+	switch {
+	case composed > 1:
+		return errors.New("rule must set only one of all, any and not")
+	case composed == 1 && r.Attribute != "":
+		return errors.New("rule cannot both compose rules and test an attribute")
+	case r.Not != nil:
+		return r.Not.Validate()
+	case composed == 1:
+		for _, rules := range [][]Rule{r.All, r.Any} {
+			for i := range rules {
+				if err := rules[i].Validate(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case r.Attribute == "" && r.Operator == "":
+		// The empty rule matches every subject
+		return nil
+	case r.Attribute == "":
+		return errors.New("rule must set an attribute")
+	}
+
+	switch r.Operator {
+	case OpEquals, OpNotEquals, OpIn, OpNotIn, OpExists:
+		return nil
+	case OpGlob:
+		for _, pattern := range r.Values {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q for %s: %w", pattern, r.Attribute, err)
+			}
+		}
+		return nil
+	case OpSemver:
+		constraint, err := parseVersionConstraint(r.Value)
+		if err != nil {
+			return fmt.Errorf("invalid constraint for %s: %w", r.Attribute, err)
+		}
+		r.constraint = constraint
+		return nil
+	default:
+		return fmt.Errorf("unknown operator %q for %s", r.Operator, r.Attribute)
+	}
+}
+
+// Matches reports whether subject satisfies the rule. An empty rule
+// matches every subject; malformed conditions match none.
+func (r Rule) Matches(subject Subject) bool {
+	switch {
+	case r.Not != nil:
+		return !r.Not.Matches(subject)
+	case r.All != nil:
+		for _, rule := range r.All {
+			if !rule.Matches(subject) {
+				return false
+			}
+		}
+		return true
+	case r.Any != nil:
+		for _, rule := range r.Any {
+			if rule.Matches(subject) {
+				return true
+			}
+		}
+		return false
+	case r.Attribute == "":
+		return true
+	}
+
+	value, ok := subject.Attributes[r.Attribute]
+	if r.Attribute == subjectIDAttribute {
+		value, ok = subject.ID, subject.ID != ""
+	}
+
+	switch r.Operator {
+	case OpExists:
+		return ok
+	case OpNotEquals:
+		return !ok || value != r.Value
+	case OpNotIn:
+		return !ok || !contains(r.Values, value)
+	}
+	if !ok {
+		return false
+	}
+
+	switch r.Operator {
+	case OpEquals:
+		return value == r.Value
+	case OpIn:
+		return contains(r.Values, value)
+	case OpGlob:
+		for _, pattern := range r.Values {
+			if matched, _ := path.Match(pattern, value); matched {
+				return true
+			}
+		}
+		return false
+	case OpSemver:
+		constraint := r.constraint
+		if constraint == nil {
+			// The rule was not validated
+			var err error
+			if constraint, err = parseVersionConstraint(r.Value); err != nil {
+				return false
+			}
+		}
+		return constraint.matches(value)
+	default:
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// versionConstraint is a set of alternatives separated by "||", each a
+// list of comparisons such as ">=1.2.0 <2.0.0" that must all hold
+type versionConstraint [][]versionComparison
+
+type versionComparison struct {
+	op      string
+	version semver.Version
+}
+
+var versionOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var constraint versionConstraint
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.Fields(alternative)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("empty version constraint %q", s)
+		}
+
+		comparisons := make([]versionComparison, 0, len(fields))
+		for _, field := range fields {
+			op := "="
+			for _, candidate := range versionOperators {
+				if strings.HasPrefix(field, candidate) {
+					op = candidate
+					break
+				}
+			}
+			version, err := parseVersion(strings.TrimPrefix(field, op))
+			if err != nil {
+				return nil, err
+			}
+			comparisons = append(comparisons, versionComparison{op: op, version: *version})
+		}
+		constraint = append(constraint, comparisons)
+	}
+	return constraint, nil
+}
+
+func parseVersion(s string) (*semver.Version, error) {
+	return semver.NewVersion(strings.TrimPrefix(s, "v"))
+}
+
+func (c versionConstraint) matches(s string) bool {
+	version, err := parseVersion(s)
+	if err != nil {
+		return false
+	}
+
+	for _, comparisons := range c {
+		if allComparisonsHold(*version, comparisons) {
+			return true
+		}
+	}
+	return false
+}
+
+func allComparisonsHold(version semver.Version, comparisons []versionComparison) bool {
+	for _, comparison := range comparisons {
+		cmp := version.Compare(comparison.version)
+		var ok bool
+		switch comparison.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// SubjectStrategy is a RolloutStrategy that decides from a request
+// subject's attributes, see WithSubject
+type SubjectStrategy interface {
+	RolloutStrategy
+	ShouldApplyTo(subject Subject) bool
+}
+
+// applyTo asks s about subject, falling back to the subject ID for keyed
+// strategies and to ShouldApply for the rest
+func applyTo(s RolloutStrategy, subject Subject) bool {
+	switch s := s.(type) {
+	case SubjectStrategy:
+		return s.ShouldApplyTo(subject)
+	case KeyedStrategy:
+		return s.ShouldApplyFor(subject.ID)
+	default:
+		return s.ShouldApply()
+	}
+}
+
+// RuleStrategy applies to subjects matching a Rule. Its rule is read from
+// a Config, so targeting can change without a redeploy.
+type RuleStrategy struct {
+	rules *Config[Rule]
+}
+
+// NewRuleStrategy returns a strategy with a fixed rule
+func NewRuleStrategy(rule Rule) (*RuleStrategy, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	rules := New[Rule]()
+	if err := rules.Update(context.Background(), rule); err != nil {
+		return nil, err
+	}
+	return &RuleStrategy{rules: rules}, nil
+}
+
+// LoadRuleStrategy loads the rule from a Config built with opts, typically
+// WithSource, and keeps it up to date as the sources change. Invalid rules
+// are rejected and the previous rule stays in effect.
+func LoadRuleStrategy(ctx context.Context, opts ...Option[Rule]) (*RuleStrategy, error) {
+	opts = append(opts,
+		WithValidation(func(_, rule Rule) error { return rule.Validate() }),
+		WithRollback[Rule](true),
+	)
+
+	rules := New(opts...)
+	if err := rules.Load(ctx); err != nil {
+		return nil, err
+	}
+	return &RuleStrategy{rules: rules}, nil
+}
+
+// Rule returns the rule currently in effect
+func (s *RuleStrategy) Rule() Rule {
+	return s.rules.Get(context.Background())
+}
+
+// ShouldApply matches the rule against a subject without attributes
+func (s *RuleStrategy) ShouldApply() bool {
+	return s.ShouldApplyTo(Subject{})
+}
+
+func (s *RuleStrategy) ShouldApplyFor(key string) bool {
+	return s.ShouldApplyTo(Subject{ID: key})
+}
+
+func (s *RuleStrategy) ShouldApplyTo(subject Subject) bool {
+	return s.Rule().Matches(subject)
+}
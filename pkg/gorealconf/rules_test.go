@@ -0,0 +1,192 @@
+package gorealconf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRuleMatches(t *testing.T) {
+	subject := Subject{
+		ID: "tenant-42",
+		Attributes: map[string]string{
+			"region":      "eu-west-1",
+			"tier":        "enterprise",
+			"app_version": "1.4.2",
+			"hostname":    "web-3.prod.example.com",
+		},
+	}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{"empty", Rule{}, true},
+		{"eq", Rule{Attribute: "tier", Operator: OpEquals, Value: "enterprise"}, true},
+		{"neq", Rule{Attribute: "tier", Operator: OpNotEquals, Value: "enterprise"}, false},
+		{"neq missing", Rule{Attribute: "plan", Operator: OpNotEquals, Value: "free"}, true},
+		{"in", Rule{Attribute: "region", Operator: OpIn, Values: []string{"us-east-1", "eu-west-1"}}, true},
+		{"not in", Rule{Attribute: "region", Operator: OpNotIn, Values: []string{"eu-west-1"}}, false},
+		{"id", Rule{Attribute: "id", Operator: OpIn, Values: []string{"tenant-42"}}, true},
+		{"glob", Rule{Attribute: "hostname", Operator: OpGlob, Values: []string{"web-*.prod.example.com"}}, true},
+		{"glob mismatch", Rule{Attribute: "hostname", Operator: OpGlob, Values: []string{"api-*"}}, false},
+		{"semver range", Rule{Attribute: "app_version", Operator: OpSemver, Value: ">=1.4.0 <2.0.0"}, true},
+		{"semver below", Rule{Attribute: "app_version", Operator: OpSemver, Value: ">=1.5.0"}, false},
+		{"semver or", Rule{Attribute: "app_version", Operator: OpSemver, Value: "<1.0.0 || =1.4.2"}, true},
+		{"exists", Rule{Attribute: "region", Operator: OpExists}, true},
+		{"missing", Rule{Attribute: "plan", Operator: OpEquals, Value: "free"}, false},
+		{"all", Rule{All: []Rule{
+			{Attribute: "tier", Operator: OpEquals, Value: "enterprise"},
+			{Attribute: "region", Operator: OpEquals, Value: "us-east-1"},
+		}}, false},
+		{"any", Rule{Any: []Rule{
+			{Attribute: "tier", Operator: OpEquals, Value: "free"},
+			{Attribute: "region", Operator: OpEquals, Value: "eu-west-1"},
+		}}, true},
+		{"not", Rule{Not: &Rule{Attribute: "tier", Operator: OpEquals, Value: "free"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := tt.rule.Matches(subject); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleConstraint(t *testing.T) {
+	subject := Subject{Attributes: map[string]string{"app_version": "1.4.2"}}
+	rule := Rule{Any: []Rule{
+		{Attribute: "tier", Operator: OpEquals, Value: "free"},
+		{Not: &Rule{Attribute: "app_version", Operator: OpSemver, Value: "<1.4.0"}},
+	}}
+
+	// Unvalidated rules parse the constraint when matched
+	if !rule.Matches(subject) {
+		t.Error("expected an unvalidated rule to match")
+	}
+
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Any[1].Not.constraint == nil {
+		t.Fatal("expected Validate to keep the parsed constraint")
+	}
+	if !rule.Matches(subject) {
+		t.Error("expected a validated rule to match")
+	}
+
+	doc := FlagDocument{Flags: map[string]Flag{"new_ui": {
+		Enabled: true,
+		Rules: []FlagRule{{
+			Match:     Rule{Attribute: "app_version", Operator: OpSemver, Value: ">=1.4.0"},
+			FlagServe: FlagServe{Variant: FlagOn},
+		}},
+	}}}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if doc.Flags["new_ui"].Rules[0].Match.constraint == nil {
+		t.Error("expected flag rules to keep the parsed constraint")
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"unknown operator", Rule{Attribute: "tier", Operator: "like"}},
+		{"missing attribute", Rule{Operator: OpEquals, Value: "x"}},
+		{"bad constraint", Rule{Attribute: "app_version", Operator: OpSemver, Value: ">=one"}},
+		{"bad pattern", Rule{Attribute: "hostname", Operator: OpGlob, Values: []string{"["}}},
+		{"mixed", Rule{Attribute: "tier", Operator: OpExists, Any: []Rule{{}}}},
+		{"nested", Rule{Not: &Rule{All: []Rule{{Attribute: "tier"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRuleStrategy(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rulesPath, []byte(`{"attribute": "region", "op": "eq", "value": "eu-west-1"}`), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source, err := NewFileSource[Rule](rulesPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	strategy, err := LoadRuleStrategy(ctx, WithSource[Rule](source))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	eu := Subject{ID: "a", Attributes: map[string]string{"region": "eu-west-1"}}
+	us := Subject{ID: "b", Attributes: map[string]string{"region": "us-east-1"}}
+
+	if !strategy.ShouldApplyTo(eu) || strategy.ShouldApplyTo(us) {
+		t.Fatal("expected only eu-west-1 to be targeted")
+	}
+
+	// Retarget without a redeploy; invalid rules are ignored
+	os.WriteFile(rulesPath, []byte(`{"attribute": "region", "op": "regex", "value": ".*"}`), 0o600)
+	time.Sleep(200 * time.Millisecond)
+	os.WriteFile(rulesPath, []byte(`{"attribute": "region", "op": "eq", "value": "us-east-1"}`), 0o600)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strategy.ShouldApplyTo(us) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strategy.ShouldApplyTo(us) || strategy.ShouldApplyTo(eu) {
+		t.Errorf("expected rule to be reloaded, got %+v", strategy.Rule())
+	}
+
+	t.Run("rollout", func(t *testing.T) {
+		type TestConfig struct {
+			Value string
+		}
+
+		strategy, err := NewRuleStrategy(Rule{Attribute: "tier", Operator: OpEquals, Value: "beta"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cfg := New[TestConfig]()
+		cfg.Update(ctx, TestConfig{Value: "stable"})
+		rollout := NewRollout(cfg).
+			WithStrategy(NewCompositeStrategy().Add(strategy)).
+			WithStages(RolloutStage{Percentage: 100, Duration: time.Hour})
+		if err := rollout.Start(ctx, TestConfig{Value: "candidate"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		beta := WithSubject(ctx, Subject{ID: "a", Attributes: map[string]string{"tier": "beta"}})
+		other := WithSubject(ctx, Subject{ID: "b", Attributes: map[string]string{"tier": "ga"}})
+		if got := cfg.GetFor(beta).Value; got != "candidate" {
+			t.Errorf("expected candidate for beta subject, got %q", got)
+		}
+		if got := cfg.GetFor(other).Value; got != "stable" {
+			t.Errorf("expected stable for other subject, got %q", got)
+		}
+	})
+}
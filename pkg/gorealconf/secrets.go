@@ -0,0 +1,217 @@
+package gorealconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// secretRefPattern matches references of the form ${scheme:ref}
+var secretRefPattern = regexp.MustCompile(`\$\{([a-z][a-z0-9_-]*):([^}]*)\}`)
+
+// SecretResolver resolves secret references of the form ${scheme:ref} found
+// in string values of a configuration
+type SecretResolver interface {
+	// Scheme returns the reference scheme handled by the resolver
+	Scheme() string
+
+	// Resolve returns the secret for ref
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// EnvResolver resolves ${env:NAME} from the process environment
+type EnvResolver struct{}
+
+func (EnvResolver) Scheme() string {
+	return "env"
+}
+
+func (EnvResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+// FileResolver resolves ${secret:file:/path} from files such as Docker or
+// Kubernetes mounted secrets. Trailing newlines are trimmed.
+type FileResolver struct{}
+
+func (FileResolver) Scheme() string {
+	return "secret"
+}
+
+func (FileResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	path, ok := strings.CutPrefix(ref, "file:")
+	if !ok {
+		return "", fmt.Errorf("unsupported secret reference %q", ref)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultResolver resolves ${vault:path#key} using the HashiCorp Vault HTTP
+// API. Both KV version 1 and version 2 (paths containing /data/) responses
+// are supported.
+type VaultResolver struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+func NewVaultResolver(address, token string) *VaultResolver {
+	return &VaultResolver{
+		address: strings.TrimRight(address, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *VaultResolver) Scheme() string {
+	return "vault"
+}
+
+func (r *VaultResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		return "", fmt.Errorf("vault reference %q must have the form path#key", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", r.token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	data := body.Data
+	if nested, ok := data["data"]; ok {
+		var kv2 map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &kv2); err == nil {
+			data = kv2
+		}
+	}
+
+	raw, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found at vault path %s", key, path)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return strings.TrimSpace(string(raw)), nil
+	}
+	return value, nil
+}
+
+// WithSecretResolver resolves ${scheme:ref} references for the resolver's
+// scheme after decoding and before validation. References with schemes that
+// have no resolver are left untouched.
+func WithSecretResolver[T any](resolver SecretResolver) Option[T] {
+	return func(c *Config[T]) {
+		if c.resolvers == nil {
+			c.resolvers = make(map[string]SecretResolver)
+		}
+		c.resolvers[resolver.Scheme()] = resolver
+	}
+}
+
+// WithSecretRefresh re-resolves secret references on the given interval
+// while the Config is loaded, applying the configuration again when a
+// secret changed
+func WithSecretRefresh[T any](interval time.Duration) Option[T] {
+	return func(c *Config[T]) {
+		c.secretRefresh = interval
+	}
+}
+
+// resolveSecrets returns a copy of value with all secret references
+// replaced. Errors name the reference, never the secret.
+func (c *Config[T]) resolveSecrets(ctx context.Context, value T) (T, error) {
+	if len(c.resolvers) == 0 {
+		return value, nil
+	}
+
+	return rewriteStrings(value, func(path string, _ bool, s string) (string, error) {
+		if !strings.Contains(s, "${") {
+			return s, nil
+		}
+
+		var resolveErr error
+		resolved := secretRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+			match := secretRefPattern.FindStringSubmatch(ref)
+			resolver, ok := c.resolvers[match[1]]
+			if !ok || resolveErr != nil {
+				return ref
+			}
+			secret, err := resolver.Resolve(ctx, match[2])
+			if err != nil {
+				resolveErr = fmt.Errorf("failed to resolve %s at %s: %w", ref, path, err)
+				return ref
+			}
+			return secret
+		})
+		return resolved, resolveErr
+	})
+}
+
+// refreshSecrets periodically re-resolves secrets and reapplies the
+// configuration when a resolved value changed
+func (c *Config[T]) refreshSecrets(ctx context.Context) {
+	ticker := time.NewTicker(c.secretRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			raw, version := c.snapshot()
+			if version == 0 {
+				continue
+			}
+
+			resolved, err := c.prepare(ctx, raw)
+			if err != nil {
+				c.metrics.IncUpdateErrors(SourceSecrets)
+				c.log(SourceSecrets).Error("secret refresh failed", LogKeyVersion, version, "error", err)
+				continue
+			}
+			if reflect.DeepEqual(resolved, c.Get(ctx)) {
+				continue
+			}
+
+			// A concurrent update already carries fresh secrets
+			var conflict *VersionConflictError
+			if err := c.update(ctx, raw, &version, SourceSecrets, 0); err != nil && !errors.As(err, &conflict) {
+				c.metrics.IncUpdateErrors(SourceSecrets)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package gorealconf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSecretResolution(t *testing.T) {
+	type Database struct {
+		DSN      string            `json:"dsn"`
+		Password string            `json:"password"`
+		Token    Sensitive[string] `json:"token"`
+	}
+	type TestConfig struct {
+		Database Database          `json:"database"`
+		Tokens   map[string]string `json:"tokens"`
+	}
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.URL.Path != "/v1/secret/data/api" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"token":"vault-token"}}}`))
+	}))
+	defer vault.Close()
+
+	secretFile := filepath.Join(t.TempDir(), "db")
+	if err := os.WriteFile(secretFile, []byte("file-password\n"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Setenv("GOREALCONF_TEST_USER", "app")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var validated TestConfig
+	cfg := New[TestConfig](
+		WithHistory[TestConfig](1),
+		WithSecretResolver[TestConfig](EnvResolver{}),
+		WithSecretResolver[TestConfig](FileResolver{}),
+		WithSecretResolver[TestConfig](NewVaultResolver(vault.URL, "root")),
+		WithSecretRefresh[TestConfig](10*time.Millisecond),
+		WithValidation[TestConfig](func(old, new TestConfig) error {
+			validated = new
+			return nil
+		}),
+	)
+
+	raw := TestConfig{
+		Database: Database{
+			DSN:      "postgres://${env:GOREALCONF_TEST_USER}@db/app",
+			Password: "${secret:file:" + secretFile + "}",
+			Token:    NewSensitive("${secret:file:" + secretFile + "}"),
+		},
+		Tokens: map[string]string{
+			"api":   "${vault:secret/data/api#token}",
+			"other": "${unknown:left-alone}",
+		},
+	}
+	if err := cfg.Update(ctx, raw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := cfg.Get(ctx)
+	if got.Database.DSN != "postgres://app@db/app" {
+		t.Errorf("unexpected DSN %q", got.Database.DSN)
+	}
+	if got.Database.Password != "file-password" {
+		t.Errorf("unexpected password %q", got.Database.Password)
+	}
+	if got.Database.Token.Value() != "file-password" {
+		t.Errorf("unexpected sensitive token %q", got.Database.Token.Value())
+	}
+	if got.Tokens["api"] != "vault-token" || got.Tokens["other"] != "${unknown:left-alone}" {
+		t.Errorf("unexpected tokens %v", got.Tokens)
+	}
+	if validated.Database.Password != "file-password" {
+		t.Error("expected validator to see resolved secrets")
+	}
+
+	if raw.Tokens["api"] != "${vault:secret/data/api#token}" || raw.Database.Token.Value() != "${secret:file:"+secretFile+"}" {
+		t.Error("resolution modified the caller's value")
+	}
+	if history := cfg.History(); history[0].Value.Database.Password != raw.Database.Password {
+		t.Error("history exposes resolved secrets")
+	}
+
+	t.Run("missing secret", func(t *testing.T) {
+		bad := raw
+		bad.Database.Password = "${env:GOREALCONF_TEST_MISSING}"
+		if err := cfg.Update(ctx, bad); err == nil {
+			t.Error("expected error for missing secret")
+		}
+		if cfg.Get(ctx).Database.Password != "file-password" {
+			t.Error("failed resolution replaced the config")
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		if err := cfg.Load(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(secretFile, []byte("rotated"), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		rotated := func() bool {
+			got := cfg.Get(ctx).Database
+			return got.Password == "rotated" && got.Token.Value() == "rotated"
+		}
+		for !rotated() {
+			select {
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for secret refresh")
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	})
+}
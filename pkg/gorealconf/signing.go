@@ -0,0 +1,337 @@
+package gorealconf
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// SignedEnvelope carries a payload together with its Ed25519 signature.
+// Payload and Signature are base64 encoded in JSON, so the signed bytes are
+// preserved exactly whatever the payload format.
+type SignedEnvelope struct {
+	KeyID     string `json:"key_id"`
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// DetachedSignature is a signature stored apart from its payload, e.g. in a
+// config.json.sig file next to config.json
+type DetachedSignature struct {
+	KeyID     string `json:"key_id"`
+	Signature []byte `json:"signature"`
+}
+
+// Signer signs configuration payloads for publishing
+type Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+func NewSigner(keyID string, key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		keyID: keyID,
+		key:   key,
+	}
+}
+
+// Sign returns payload wrapped in a signed envelope
+func (s *Signer) Sign(payload []byte) ([]byte, error) {
+	return json.Marshal(SignedEnvelope{
+		KeyID:     s.keyID,
+		Payload:   payload,
+		Signature: ed25519.Sign(s.key, payload),
+	})
+}
+
+// SignDetached returns a detached signature for payload
+func (s *Signer) SignDetached(payload []byte) ([]byte, error) {
+	return json.Marshal(DetachedSignature{
+		KeyID:     s.keyID,
+		Signature: ed25519.Sign(s.key, payload),
+	})
+}
+
+type trustedKey struct {
+	key      ed25519.PublicKey
+	notAfter time.Time
+}
+
+// TrustStore holds the Ed25519 public keys trusted to sign configuration.
+// Rotate trust roots by adding the new key, re-signing payloads, and then
+// removing or expiring the old key.
+type TrustStore struct {
+	mu   sync.RWMutex
+	keys map[string]trustedKey
+	now  func() time.Time
+}
+
+type trustStoreFile struct {
+	Keys []struct {
+		ID        string    `json:"id"`
+		PublicKey []byte    `json:"public_key"`
+		NotAfter  time.Time `json:"not_after,omitempty"`
+	} `json:"keys"`
+}
+
+func NewTrustStore() *TrustStore {
+	return &TrustStore{
+		keys: make(map[string]trustedKey),
+		now:  time.Now,
+	}
+}
+
+// LoadTrustStore reads trusted keys from a JSON file of the form
+// {"keys": [{"id": "...", "public_key": "<base64>", "not_after": "<RFC 3339>"}]}
+func LoadTrustStore(path string) (*TrustStore, error) {
+	t := NewTrustStore()
+	if err := t.Reload(path); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload atomically replaces the trusted keys with those in path
+func (t *TrustStore) Reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file trustStoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid trust store %s: %w", path, err)
+	}
+
+	keys := make(map[string]trustedKey, len(file.Keys))
+	for _, k := range file.Keys {
+		if len(k.PublicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key %s in trust store %s", k.ID, path)
+		}
+		keys[k.ID] = trustedKey{key: k.PublicKey, notAfter: k.NotAfter}
+	}
+
+	t.mu.Lock()
+	t.keys = keys
+	t.mu.Unlock()
+	return nil
+}
+
+// AddKey trusts key under id. A non-zero notAfter limits how long the key
+// is trusted, which lets an old root expire on its own after a rotation.
+func (t *TrustStore) AddKey(id string, key ed25519.PublicKey, notAfter time.Time) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key %s", id)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys[id] = trustedKey{key: key, notAfter: notAfter}
+	return nil
+}
+
+// RemoveKey stops trusting the key with the given id
+func (t *TrustStore) RemoveKey(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.keys, id)
+}
+
+// Verify checks a signature made by the key with the given id
+func (t *TrustStore) Verify(keyID string, payload, signature []byte) error {
+	t.mu.RLock()
+	trusted, ok := t.keys[keyID]
+	t.mu.RUnlock()
+
+	switch {
+	case keyID == "" || len(signature) == 0:
+		return &SignatureError{KeyID: keyID, Reason: "payload is not signed"}
+	case !ok:
+		return &SignatureError{KeyID: keyID, Reason: "key is not trusted"}
+	case !trusted.notAfter.IsZero() && t.now().After(trusted.notAfter):
+		return &SignatureError{KeyID: keyID, Reason: "key expired"}
+	case !ed25519.Verify(trusted.key, payload, signature):
+		return &SignatureError{KeyID: keyID, Reason: "invalid signature"}
+	}
+	return nil
+}
+
+// Open verifies a signed envelope and returns its payload
+func (t *TrustStore) Open(data []byte) ([]byte, error) {
+	var envelope SignedEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Payload == nil {
+		return nil, &SignatureError{Reason: "payload is not a signed envelope"}
+	}
+	if err := t.Verify(envelope.KeyID, envelope.Payload, envelope.Signature); err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// WithSignatureVerification requires payloads from every source to be
+// signed envelopes verified against trust. Unsigned or badly signed
+// payloads are rejected before decoding and validation. Sources that do
+// not implement RawSource cannot be verified and are rejected as well, and
+// an AdminHandler accepts only signed PUT bodies. Values passed to Update
+// directly are trusted.
+func WithSignatureVerification[T any](trust *TrustStore) Option[T] {
+	return func(c *Config[T]) {
+		c.trust = trust
+	}
+}
+
+// verify opens a signed payload when verification is enabled
+func (c *Config[T]) verify(data []byte) ([]byte, error) {
+	if c.trust == nil {
+		return data, nil
+	}
+
+	payload, err := c.trust.Open(data)
+	if err != nil {
+		c.metrics.IncVerificationFailures()
+		return nil, err
+	}
+	return payload, nil
+}
+
+// DetachedSignatureSource combines a payload source with a source of
+// detached signatures, emitting signed envelopes for use with
+// WithSignatureVerification. Its typed Load and Watch methods decode the
+// payload without verifying it.
+type DetachedSignatureSource[T any] struct {
+	payload   RawSource
+	signature RawSource
+	settle    time.Duration // how long a lone change waits for the other side
+}
+
+func NewDetachedSignatureSource[T any](payload, signature RawSource) *DetachedSignatureSource[T] {
+	return &DetachedSignatureSource[T]{
+		payload:   payload,
+		signature: signature,
+		settle:    time.Second,
+	}
+}
+
+func (s *DetachedSignatureSource[T]) Load(ctx context.Context) (T, error) {
+	data, err := s.payload.LoadRaw(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return decodeJSON[T](data)
+}
+
+func (s *DetachedSignatureSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	raw, err := s.payload.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeWatch[T](ctx, raw), nil
+}
+
+func (s *DetachedSignatureSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	payload, err := s.payload.LoadRaw(ctx)
+	if err != nil || payload == nil {
+		return nil, err
+	}
+	signature, err := s.signature.LoadRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return envelopeFromDetached(payload, signature)
+}
+
+// WatchRaw emits a new envelope whenever the payload or the signature
+// changes. Publishing rewrites both, so a change to one side is held until
+// the other side changes too, or for a second if it does not. This keeps
+// a new payload from being emitted with its old signature.
+func (s *DetachedSignatureSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	payloads, err := s.payload.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := s.signature.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte, 1)
+	go func() {
+		defer close(ch)
+
+		// Start from the current state so a change to either side can be
+		// paired with the other
+		payload, _ := s.payload.LoadRaw(ctx)
+		signature, _ := s.signature.LoadRaw(ctx)
+
+		var (
+			payloadChanged, signatureChanged bool
+			settle                           <-chan time.Time
+		)
+		for payloads != nil || signatures != nil {
+			settled := false
+			select {
+			case <-ctx.Done():
+				return
+			case data, ok := <-payloads:
+				if !ok {
+					payloads = nil
+					continue
+				}
+				payload, payloadChanged = data, true
+			case data, ok := <-signatures:
+				if !ok {
+					signatures = nil
+					continue
+				}
+				signature, signatureChanged = data, true
+			case <-settle:
+				settled = true
+			}
+
+			if payloadChanged != signatureChanged && !settled {
+				if settle == nil {
+					settle = time.After(s.settle)
+				}
+				continue
+			}
+			payloadChanged, signatureChanged, settle = false, false, nil
+
+			if payload == nil || signature == nil {
+				continue
+			}
+			envelope, err := envelopeFromDetached(payload, signature)
+			if err != nil {
+				continue
+			}
+			select {
+			case ch <- envelope:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func envelopeFromDetached(payload, signature []byte) ([]byte, error) {
+	var detached DetachedSignature
+	if signature != nil {
+		if err := json.Unmarshal(signature, &detached); err != nil {
+			return nil, errors.New("invalid detached signature")
+		}
+	}
+	return json.Marshal(SignedEnvelope{
+		KeyID:     detached.KeyID,
+		Payload:   payload,
+		Signature: detached.Signature,
+	})
+}
@@ -0,0 +1,201 @@
+package gorealconf
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// rawTestSource serves fixed payloads through the RawSource interface
+type rawTestSource[T any] struct {
+	data    []byte
+	changes chan []byte
+}
+
+func (s *rawTestSource[T]) Load(ctx context.Context) (T, error) {
+	return decodeJSON[T](s.data)
+}
+
+func (s *rawTestSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	return decodeWatch[T](ctx, s.changes), nil
+}
+
+func (s *rawTestSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	return s.data, nil
+}
+
+func (s *rawTestSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	return s.changes, nil
+}
+
+func TestSignatureVerification(t *testing.T) {
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+
+	oldPub, oldKey, _ := ed25519.GenerateKey(nil)
+	newPub, newKey, _ := ed25519.GenerateKey(nil)
+	_, untrustedKey, _ := ed25519.GenerateKey(nil)
+
+	trust := NewTrustStore()
+	if err := trust.AddKey("old", oldPub, time.Time{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sign := func(signer *Signer, value string) []byte {
+		envelope, err := signer.Sign([]byte(`{"value":"` + value + `"}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return envelope
+	}
+
+	t.Run("load", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			payload []byte
+			wantErr bool
+		}{
+			{"signed", sign(NewSigner("old", oldKey), "v1"), false},
+			{"unsigned", []byte(`{"value":"v1"}`), true},
+			{"untrusted key", sign(NewSigner("other", untrustedKey), "v1"), true},
+			{"forged key id", sign(NewSigner("old", untrustedKey), "v1"), true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				metrics := NewMetrics("test")
+				cfg := New[TestConfig](
+					WithSource[TestConfig](&rawTestSource[TestConfig]{data: tt.payload, changes: make(chan []byte)}),
+					WithSignatureVerification[TestConfig](trust),
+					WithMetrics[TestConfig](metrics),
+				)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				err := cfg.Load(ctx)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				var sigErr *SignatureError
+				if tt.wantErr && !errors.As(err, &sigErr) {
+					t.Errorf("expected *SignatureError, got %v", err)
+				}
+				if got := testutil.ToFloat64(metrics.verifyErrors); tt.wantErr && got != 1 {
+					t.Errorf("expected 1 verification failure, got %v", got)
+				}
+			})
+		}
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		if err := trust.AddKey("new", newPub, time.Time{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := trust.Open(sign(NewSigner("new", newKey), "v2")); err != nil {
+			t.Errorf("expected new key to be trusted: %v", err)
+		}
+
+		trust.RemoveKey("old")
+		if _, err := trust.Open(sign(NewSigner("old", oldKey), "v2")); err == nil {
+			t.Error("expected removed key to be rejected")
+		}
+
+		expiring := NewTrustStore()
+		expiring.AddKey("old", oldPub, time.Now().Add(-time.Minute))
+		if _, err := expiring.Open(sign(NewSigner("old", oldKey), "v2")); err == nil {
+			t.Error("expected expired key to be rejected")
+		}
+	})
+
+	t.Run("detached", func(t *testing.T) {
+		dir := t.TempDir()
+		payload := []byte(`{"value":"detached"}`)
+		signature, err := NewSigner("new", newKey).SignDetached(payload)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		configPath := filepath.Join(dir, "config.json")
+		os.WriteFile(configPath, payload, 0o600)
+		os.WriteFile(configPath+".sig", signature, 0o600)
+
+		payloadSource, _ := NewFileSource[TestConfig](configPath)
+		signatureSource, _ := NewFileSource[TestConfig](configPath + ".sig")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := New[TestConfig](
+			WithSource[TestConfig](NewDetachedSignatureSource[TestConfig](payloadSource, signatureSource)),
+			WithSignatureVerification[TestConfig](trust),
+		)
+		if err := cfg.Load(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cfg.Get(ctx).Value; got != "detached" {
+			t.Errorf("expected detached, got %q", got)
+		}
+	})
+	t.Run("detached rotation", func(t *testing.T) {
+		signer := NewSigner("new", newKey)
+		signed := func(value string) ([]byte, []byte) {
+			payload := []byte(`{"value":"` + value + `"}`)
+			signature, err := signer.SignDetached(payload)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return payload, signature
+		}
+		payload, signature := signed("v1")
+		payloadSource := &rawTestSource[TestConfig]{data: payload, changes: make(chan []byte)}
+		signatureSource := &rawTestSource[TestConfig]{data: signature, changes: make(chan []byte)}
+		source := NewDetachedSignatureSource[TestConfig](payloadSource, signatureSource)
+		source.settle = 50 * time.Millisecond
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		envelopes, err := source.WatchRaw(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		next := func() []byte {
+			select {
+			case envelope := <-envelopes:
+				return envelope
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for an envelope")
+				return nil
+			}
+		}
+
+		// The new payload waits for its signature
+		payload, signature = signed("v2")
+		payloadSource.changes <- payload
+		select {
+		case envelope := <-envelopes:
+			t.Fatalf("payload emitted before its signature: %s", envelope)
+		case <-time.After(20 * time.Millisecond):
+		}
+		signatureSource.changes <- signature
+		opened, err := trust.Open(next())
+		if err != nil || string(opened) != string(payload) {
+			t.Errorf("expected the verified v2 payload, got %s: %v", opened, err)
+		}
+
+		// A lone change is emitted once it settles
+		_, signature = signed("v3")
+		signatureSource.changes <- signature
+		if _, err := trust.Open(next()); err == nil {
+			t.Error("expected the mismatched signature to fail verification")
+		}
+	})
+}
@@ -1,6 +1,11 @@
 package gorealconf
 
-import "context"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
 
 // Source represents a configuration source
 type Source[T any] interface {
@@ -10,3 +15,67 @@ type Source[T any] interface {
 	// Watch watches for configuration changes
 	Watch(ctx context.Context) (<-chan T, error)
 }
+
+// WritableSource is a Source that values can be written back to
+type WritableSource[T any] interface {
+	Source[T]
+
+	// Save stores value, replacing the current one
+	Save(ctx context.Context, value T) error
+}
+
+// CASSource is a RawSource whose payload can be replaced atomically.
+// Versions are opaque and specific to the store, such as an etcd revision
+// or a Consul modify index.
+type CASSource interface {
+	RawSource
+
+	// LoadVersion loads the payload with its version. Both are empty when
+	// the source holds no configuration yet.
+	LoadVersion(ctx context.Context) ([]byte, string, error)
+
+	// CompareAndSwap stores data if the current version is version, where
+	// an empty version means no configuration may exist yet. It returns
+	// the new version, or a *CASConflictError.
+	CompareAndSwap(ctx context.Context, version string, data []byte) (string, error)
+}
+
+// SourceRevision is a stored version of a source's payload
+type SourceRevision struct {
+	Version    string
+	ModifiedAt time.Time // zero when the store does not record it
+	Data       []byte    // nil when the payload was deleted
+}
+
+// HistorySource is a RawSource whose store keeps previous payloads
+type HistorySource interface {
+	RawSource
+
+	// LoadHistory returns up to limit revisions, newest first, or every
+	// retained revision when limit is 0
+	LoadHistory(ctx context.Context, limit int) ([]SourceRevision, error)
+}
+
+// contentVersion versions payloads by content for stores without native
+// versions
+func contentVersion(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// NamedSource is a Source that names itself. The name labels the source's
+// metrics; WithNamedSource overrides it.
+type NamedSource interface {
+	Name() string
+}
+
+// Names of updates that do not come from a source
+const (
+	SourceManual     = "manual"     // Config.Update and Config.UpdateIfVersion
+	SourceRollout    = "rollout"    // promotion of a rollout candidate
+	SourceExperiment = "experiment" // the winner of an experiment
+	SourceSecrets    = "secrets"    // re-resolved secrets
+)
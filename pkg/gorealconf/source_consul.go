@@ -3,6 +3,8 @@ package gorealconf
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/consul/api"
@@ -28,29 +30,46 @@ func NewConsulSource[T any](address string, key string) (*ConsulSource[T], error
 	}, nil
 }
 
+// Name identifies the source in metrics
+func (s *ConsulSource[T]) Name() string {
+	return "consul:" + s.key
+}
+
 func (s *ConsulSource[T]) Load(ctx context.Context) (T, error) {
-	pair, _, err := s.client.KV().Get(s.key, nil)
+	data, err := s.LoadRaw(ctx)
 	if err != nil {
 		var zero T
 		return zero, err
 	}
 
-	if pair == nil {
-		var zero T
-		return zero, nil
+	return decodeJSON[T](data)
+}
+
+func (s *ConsulSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	raw, err := s.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	var value T
-	if err := json.Unmarshal(pair.Value, &value); err != nil {
-		var zero T
-		return zero, err
+	return decodeWatch[T](ctx, raw), nil
+}
+
+func (s *ConsulSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	pair, _, err := s.client.KV().Get(s.key, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return value, nil
+	if pair == nil {
+		return nil, nil
+	}
+
+	return pair.Value, nil
 }
 
-func (s *ConsulSource[T]) Watch(ctx context.Context) (<-chan T, error) {
-	ch := make(chan T, 1)
+func (s *ConsulSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	logger := LoggerFromContext(ctx)
+	ch := make(chan []byte, 1)
 
 	go func() {
 		defer close(ch)
@@ -66,6 +85,7 @@ func (s *ConsulSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 					WaitTime:  5 * time.Minute,
 				})
 				if err != nil {
+					logger.Warn("consul query failed", "key", s.key, "error", err)
 					time.Sleep(time.Second)
 					continue
 				}
@@ -80,13 +100,8 @@ func (s *ConsulSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 					continue
 				}
 
-				var value T
-				if err := json.Unmarshal(pair.Value, &value); err != nil {
-					continue
-				}
-
 				select {
-				case ch <- value:
+				case ch <- pair.Value:
 				case <-ctx.Done():
 					return
 				}
@@ -96,3 +111,55 @@ func (s *ConsulSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 
 	return ch, nil
 }
+
+// LoadVersion loads the payload with its modify index
+func (s *ConsulSource[T]) LoadVersion(ctx context.Context) ([]byte, string, error) {
+	pair, _, err := s.client.KV().Get(s.key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, "", err
+	}
+	if pair == nil {
+		return nil, "", nil
+	}
+	return pair.Value, strconv.FormatUint(pair.ModifyIndex, 10), nil
+}
+
+// CompareAndSwap stores data with a check-and-set on the key's modify
+// index
+func (s *ConsulSource[T]) CompareAndSwap(ctx context.Context, version string, data []byte) (string, error) {
+	var index uint64
+	if version != "" {
+		var err error
+		if index, err = strconv.ParseUint(version, 10, 64); err != nil {
+			return "", fmt.Errorf("invalid consul index %q", version)
+		}
+	}
+
+	ok, _, err := s.client.KV().CAS(&api.KVPair{
+		Key:         s.key,
+		Value:       data,
+		ModifyIndex: index,
+	}, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+
+	_, current, err := s.LoadVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", &CASConflictError{Expected: version, Actual: current}
+	}
+	return current, nil
+}
+
+// Save stores value under the key as JSON
+func (s *ConsulSource[T]) Save(ctx context.Context, value T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.KV().Put(&api.KVPair{Key: s.key, Value: data}, (&api.WriteOptions{}).WithContext(ctx))
+	return err
+}
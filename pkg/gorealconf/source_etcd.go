@@ -3,7 +3,11 @@ package gorealconf
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -26,40 +30,59 @@ func NewEtcdSource[T any](endpoints []string, key string) (*EtcdSource[T], error
 	}, nil
 }
 
+// Name identifies the source in metrics
+func (s *EtcdSource[T]) Name() string {
+	return "etcd:" + s.key
+}
+
 func (s *EtcdSource[T]) Load(ctx context.Context) (T, error) {
-	resp, err := s.client.Get(ctx, s.key)
+	data, err := s.LoadRaw(ctx)
 	if err != nil {
 		var zero T
 		return zero, err
 	}
 
-	if len(resp.Kvs) == 0 {
-		var zero T
-		return zero, nil
+	return decodeJSON[T](data)
+}
+
+func (s *EtcdSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	raw, err := s.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	var config T
-	if err := json.Unmarshal(resp.Kvs[0].Value, &config); err != nil {
-		var zero T
-		return zero, err
+	return decodeWatch[T](ctx, raw), nil
+}
+
+func (s *EtcdSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	resp, err := s.client.Get(ctx, s.key)
+	if err != nil {
+		return nil, err
 	}
 
-	return config, nil
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+
+	return resp.Kvs[0].Value, nil
 }
 
-func (s *EtcdSource[T]) Watch(ctx context.Context) (<-chan T, error) {
-	ch := make(chan T, 1)
+func (s *EtcdSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
 	watcher := s.client.Watch(ctx, s.key)
 
+	logger := LoggerFromContext(ctx)
+
 	go func() {
 		defer close(ch)
 		for resp := range watcher {
+			if err := resp.Err(); err != nil {
+				logger.Warn("etcd watch error", "key", s.key, "error", err)
+				continue
+			}
 			for _, ev := range resp.Events {
 				if ev.Type == clientv3.EventTypePut {
-					var config T
-					if err := json.Unmarshal(ev.Kv.Value, &config); err == nil {
-						ch <- config
-					}
+					ch <- ev.Kv.Value
 				}
 			}
 		}
@@ -67,3 +90,93 @@ func (s *EtcdSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 
 	return ch, nil
 }
+
+// LoadVersion loads the payload with its modification revision
+func (s *EtcdSource[T]) LoadVersion(ctx context.Context) ([]byte, string, error) {
+	resp, err := s.client.Get(ctx, s.key)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, "", nil
+	}
+	kv := resp.Kvs[0]
+	return kv.Value, strconv.FormatInt(kv.ModRevision, 10), nil
+}
+
+// CompareAndSwap stores data in a transaction conditional on the key's
+// modification revision
+func (s *EtcdSource[T]) CompareAndSwap(ctx context.Context, version string, data []byte) (string, error) {
+	cmp := clientv3.Compare(clientv3.CreateRevision(s.key), "=", 0)
+	if version != "" {
+		revision, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid etcd revision %q", version)
+		}
+		cmp = clientv3.Compare(clientv3.ModRevision(s.key), "=", revision)
+	}
+
+	resp, err := s.client.Txn(ctx).
+		If(cmp).
+		Then(clientv3.OpPut(s.key, string(data))).
+		Else(clientv3.OpGet(s.key)).
+		Commit()
+	if err != nil {
+		return "", err
+	}
+	if !resp.Succeeded {
+		var actual string
+		if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+			actual = strconv.FormatInt(kvs[0].ModRevision, 10)
+		}
+		return "", &CASConflictError{Expected: version, Actual: actual}
+	}
+	return strconv.FormatInt(resp.Header.Revision, 10), nil
+}
+
+// LoadHistory walks the key's previous revisions until limit is reached,
+// the key was created, or the revisions were compacted
+func (s *EtcdSource[T]) LoadHistory(ctx context.Context, limit int) ([]SourceRevision, error) {
+	var revisions []SourceRevision
+
+	var opts []clientv3.OpOption
+	for limit == 0 || len(revisions) < limit {
+		resp, err := s.client.Get(ctx, s.key, opts...)
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Kvs) == 0 {
+			break
+		}
+
+		kv := resp.Kvs[0]
+		revisions = append(revisions, SourceRevision{
+			Version: strconv.FormatInt(kv.ModRevision, 10),
+			Data:    kv.Value,
+		})
+		if kv.ModRevision <= kv.CreateRevision {
+			break
+		}
+		opts = []clientv3.OpOption{clientv3.WithRev(kv.ModRevision - 1)}
+	}
+
+	return revisions, nil
+}
+
+// Close closes the etcd client
+func (s *EtcdSource[T]) Close() error {
+	return s.client.Close()
+}
+
+// Save stores value under the key as JSON
+func (s *EtcdSource[T]) Save(ctx context.Context, value T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Put(ctx, s.key, string(data))
+	return err
+}
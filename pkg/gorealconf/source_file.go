@@ -3,7 +3,10 @@ package gorealconf
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -11,6 +14,7 @@ import (
 type FileSource[T any] struct {
 	path    string
 	watcher *fsnotify.Watcher
+	mu      sync.Mutex // serializes CompareAndSwap
 }
 
 func NewFileSource[T any](path string) (*FileSource[T], error) {
@@ -25,28 +29,41 @@ func NewFileSource[T any](path string) (*FileSource[T], error) {
 	}, nil
 }
 
+// Name identifies the source in metrics
+func (s *FileSource[T]) Name() string {
+	return "file:" + s.path
+}
+
 func (s *FileSource[T]) Load(ctx context.Context) (T, error) {
-	data, err := os.ReadFile(s.path)
+	data, err := s.LoadRaw(ctx)
 	if err != nil {
 		var zero T
 		return zero, err
 	}
 
-	var config T
-	if err := json.Unmarshal(data, &config); err != nil {
-		var zero T
-		return zero, err
+	return decodeJSON[T](data)
+}
+
+func (s *FileSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	raw, err := s.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return config, nil
+	return decodeWatch[T](ctx, raw), nil
 }
 
-func (s *FileSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+func (s *FileSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	return os.ReadFile(s.path)
+}
+
+func (s *FileSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
 	if err := s.watcher.Add(s.path); err != nil {
 		return nil, err
 	}
 
-	ch := make(chan T, 1)
+	logger := LoggerFromContext(ctx)
+	ch := make(chan []byte, 1)
 	go func() {
 		defer close(ch)
 		defer s.watcher.Close()
@@ -55,11 +72,22 @@ func (s *FileSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 			select {
 			case <-ctx.Done():
 				return
-			case event := <-s.watcher.Events:
+			case err, ok := <-s.watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("file watch error", "path", s.path, "error", err)
+			case event, ok := <-s.watcher.Events:
+				if !ok {
+					return
+				}
 				if event.Op&fsnotify.Write == fsnotify.Write {
-					if config, err := s.Load(ctx); err == nil {
-						ch <- config
+					data, err := s.LoadRaw(ctx)
+					if err != nil {
+						logger.Warn("file read failed", "path", s.path, "error", err)
+						continue
 					}
+					ch <- data
 				}
 			}
 		}
@@ -67,3 +95,47 @@ func (s *FileSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 
 	return ch, nil
 }
+
+// LoadVersion loads the file with a version derived from its content. A
+// missing file holds no configuration.
+func (s *FileSource[T]) LoadVersion(ctx context.Context) ([]byte, string, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentVersion(data), nil
+}
+
+// CompareAndSwap writes data if the file's content still has version. It
+// guards against concurrent writers in this process and against changes
+// made since version was read; writers in other processes may still race.
+func (s *FileSource[T]) CompareAndSwap(ctx context.Context, version string, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, current, err := s.LoadVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	if current != version {
+		return "", &CASConflictError{Expected: version, Actual: current}
+	}
+
+	// Written in place so watchers of the file see a write event
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		return "", err
+	}
+	return contentVersion(data), nil
+}
+
+// Save writes value to the file as JSON, readable by the owner only
+func (s *FileSource[T]) Save(ctx context.Context, value T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
@@ -0,0 +1,59 @@
+package gorealconf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSourceCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config.json")
+	source, err := NewFileSource[map[string]any](path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, version, err := source.LoadVersion(ctx)
+	if err != nil || data != nil || version != "" {
+		t.Fatalf("expected no payload for a missing file, got %q, %q, %v", data, version, err)
+	}
+
+	v1, err := source.CompareAndSwap(ctx, "", []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		version string
+	}{
+		{"missing file expected", ""},
+		{"stale version", "0000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := source.CompareAndSwap(ctx, tt.version, []byte(`{"a":2}`))
+			var conflict *CASConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("expected CASConflictError, got %v", err)
+			}
+			if conflict.Actual != v1 {
+				t.Errorf("expected actual version %s, got %s", v1, conflict.Actual)
+			}
+		})
+	}
+
+	v2, err := source.CompareAndSwap(ctx, v1, []byte(`{"a":2}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v2 == v1 {
+		t.Error("expected a new version after the write")
+	}
+	if got, _ := os.ReadFile(path); string(got) != `{"a":2}` {
+		t.Errorf("unexpected file content %s", got)
+	}
+}
@@ -0,0 +1,153 @@
+package gorealconf
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// GRPCSource loads configuration from a GRPCServer. Watch reconnects after
+// failures and resumes from the last version it received.
+type GRPCSource[T any] struct {
+	conn *grpc.ClientConn
+
+	mu      sync.Mutex
+	epoch   string
+	version uint64
+}
+
+func NewGRPCSource[T any](target string, opts ...grpc.DialOption) (*GRPCSource[T], error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCSource[T]{
+		conn: conn,
+	}, nil
+}
+
+// Name identifies the source in metrics
+func (s *GRPCSource[T]) Name() string {
+	return "grpc:" + s.conn.Target()
+}
+
+func (s *GRPCSource[T]) Load(ctx context.Context) (T, error) {
+	data, err := s.LoadRaw(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return decodeJSON[T](data)
+}
+
+func (s *GRPCSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	raw, err := s.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeWatch[T](ctx, raw), nil
+}
+
+func (s *GRPCSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	update := new(ConfigUpdate)
+	err := s.conn.Invoke(ctx, grpcGetMethod, &WatchRequest{}, update, grpc.CallContentSubtype(grpcCodecName))
+	if err != nil {
+		return nil, err
+	}
+
+	s.setVersion(update.Epoch, update.Version)
+	return update.Payload, nil
+}
+
+func (s *GRPCSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
+	logger := LoggerFromContext(ctx)
+
+	go func() {
+		defer close(ch)
+
+		backoff := 100 * time.Millisecond
+		for {
+			received, err := s.stream(ctx, ch)
+			if ctx.Err() != nil {
+				return
+			}
+			if received {
+				backoff = 100 * time.Millisecond
+			}
+			logger.Warn("grpc watch error", "target", s.conn.Target(), "error", err, "retry", backoff)
+			if !sleepContext(ctx, backoff) {
+				return
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Version returns the last version received from the server
+func (s *GRPCSource[T]) Version() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// Close closes the underlying connection
+func (s *GRPCSource[T]) Close() error {
+	return s.conn.Close()
+}
+
+// stream consumes a single Watch stream until it fails. It reports whether
+// any update was received.
+func (s *GRPCSource[T]) stream(ctx context.Context, ch chan<- []byte) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.conn.NewStream(ctx, &configServiceDesc.Streams[0], grpcWatchMethod, grpc.CallContentSubtype(grpcCodecName))
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	req := &WatchRequest{Epoch: s.epoch, FromVersion: s.version}
+	s.mu.Unlock()
+
+	if err := stream.SendMsg(req); err != nil {
+		return false, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return false, err
+	}
+
+	received := false
+	for {
+		update := new(ConfigUpdate)
+		if err := stream.RecvMsg(update); err != nil {
+			return received, err
+		}
+		received = true
+		s.setVersion(update.Epoch, update.Version)
+
+		select {
+		case ch <- update.Payload:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
+
+func (s *GRPCSource[T]) setVersion(epoch string, version uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if epoch != s.epoch || version > s.version {
+		s.epoch = epoch
+		s.version = version
+	}
+}
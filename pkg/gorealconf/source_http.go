@@ -0,0 +1,549 @@
+package gorealconf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPWatchMode selects how an HTTPSource watches for changes
+type HTTPWatchMode int
+
+const (
+	// HTTPPoll issues conditional GET requests on an interval
+	HTTPPoll HTTPWatchMode = iota
+	// HTTPLongPoll issues conditional GET requests that the server may hold
+	// open until the configuration changes
+	HTTPLongPoll
+	// HTTPServerSentEvents consumes a text/event-stream where each event
+	// carries a full configuration payload
+	HTTPServerSentEvents
+)
+
+// HTTPAuth decorates outgoing requests with credentials
+type HTTPAuth func(req *http.Request) error
+
+// BearerAuth returns an HTTPAuth that sets a bearer token
+func BearerAuth(token string) HTTPAuth {
+	return func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// BasicAuth returns an HTTPAuth that sets basic credentials
+func BasicAuth(username, password string) HTTPAuth {
+	return func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	}
+}
+
+// HeaderAuth returns an HTTPAuth that sets a static header
+func HeaderAuth(key, value string) HTTPAuth {
+	return func(req *http.Request) error {
+		req.Header.Set(key, value)
+		return nil
+	}
+}
+
+type httpSourceOptions struct {
+	client          *http.Client
+	tlsConfig       *tls.Config
+	auth            []HTTPAuth
+	mode            HTTPWatchMode
+	interval        time.Duration
+	longPollTimeout time.Duration
+}
+
+// HTTPOption configures an HTTPSource
+type HTTPOption func(*httpSourceOptions)
+
+// WithHTTPClient sets the client used for all requests
+func WithHTTPClient(client *http.Client) HTTPOption {
+	return func(o *httpSourceOptions) {
+		o.client = client
+	}
+}
+
+// WithHTTPTLSConfig sets the TLS configuration, e.g. for mutual TLS
+func WithHTTPTLSConfig(config *tls.Config) HTTPOption {
+	return func(o *httpSourceOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithHTTPAuth adds a request decorator applied before every request
+func WithHTTPAuth(auth HTTPAuth) HTTPOption {
+	return func(o *httpSourceOptions) {
+		o.auth = append(o.auth, auth)
+	}
+}
+
+// WithHTTPWatchMode sets how the source watches for changes
+func WithHTTPWatchMode(mode HTTPWatchMode) HTTPOption {
+	return func(o *httpSourceOptions) {
+		o.mode = mode
+	}
+}
+
+// WithHTTPPollInterval sets the delay between polls when the server does
+// not send a Cache-Control max-age, the reconnect delay after errors, and
+// the minimum gap between long-poll requests that return unchanged
+func WithHTTPPollInterval(interval time.Duration) HTTPOption {
+	return func(o *httpSourceOptions) {
+		o.interval = interval
+	}
+}
+
+// WithHTTPLongPollTimeout sets how long the server is asked to hold a
+// long-poll request open
+func WithHTTPLongPollTimeout(timeout time.Duration) HTTPOption {
+	return func(o *httpSourceOptions) {
+		o.longPollTimeout = timeout
+	}
+}
+
+// LoadClientTLSConfig builds a TLS configuration for mutual TLS from PEM
+// encoded files. caFile may be empty to use the system roots.
+func LoadClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// HTTPSource loads configuration from an HTTP endpoint. Requests are
+// conditional on the last seen ETag and Last-Modified values, so unchanged
+// configuration costs a 304 response.
+type HTTPSource[T any] struct {
+	client          *http.Client
+	url             string
+	name            string
+	auth            []HTTPAuth
+	mode            HTTPWatchMode
+	interval        time.Duration
+	longPollTimeout time.Duration
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	maxAge       time.Duration
+	lastEventID  string
+}
+
+func NewHTTPSource[T any](rawURL string, opts ...HTTPOption) (*HTTPSource[T], error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+
+	options := httpSourceOptions{
+		mode:            HTTPPoll,
+		interval:        30 * time.Second,
+		longPollTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	client := options.client
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = options.tlsConfig
+		client = &http.Client{Transport: transport}
+	} else if options.tlsConfig != nil {
+		return nil, errors.New("WithHTTPTLSConfig cannot be combined with WithHTTPClient")
+	}
+
+	return &HTTPSource[T]{
+		client:          client,
+		url:             u.String(),
+		name:            u.Scheme + "://" + u.Host + u.Path,
+		auth:            options.auth,
+		mode:            options.mode,
+		interval:        options.interval,
+		longPollTimeout: options.longPollTimeout,
+	}, nil
+}
+
+// Name identifies the source in metrics. Credentials and the query
+// string are left out.
+func (s *HTTPSource[T]) Name() string {
+	return s.name
+}
+
+func (s *HTTPSource[T]) Load(ctx context.Context) (T, error) {
+	data, err := s.LoadRaw(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return decodeJSON[T](data)
+}
+
+func (s *HTTPSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	raw, err := s.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeWatch[T](ctx, raw), nil
+}
+
+func (s *HTTPSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	data, _, err := s.fetch(ctx, false, 0)
+	return data, err
+}
+
+func (s *HTTPSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
+
+	go func() {
+		defer close(ch)
+
+		switch s.mode {
+		case HTTPServerSentEvents:
+			s.watchEvents(ctx, ch)
+		default:
+			s.watchPoll(ctx, ch)
+		}
+	}()
+
+	return ch, nil
+}
+
+func (s *HTTPSource[T]) watchPoll(ctx context.Context, ch chan<- []byte) {
+	longPoll := s.mode == HTTPLongPoll
+	var last []byte
+
+	for {
+		if !longPoll {
+			if !sleepContext(ctx, s.nextPoll()) {
+				return
+			}
+		}
+
+		var wait time.Duration
+		if longPoll {
+			wait = s.longPollTimeout
+		}
+
+		start := time.Now()
+		data, changed, err := s.fetch(ctx, true, wait)
+		if err != nil {
+			LoggerFromContext(ctx).Warn("http poll failed", "error", err)
+			if !sleepContext(ctx, s.interval) {
+				return
+			}
+			continue
+		}
+		if changed && bytes.Equal(data, last) {
+			changed = false
+		}
+		if !changed {
+			// A server or proxy that ignores Prefer: wait answers at once;
+			// keep at least the poll interval between its answers
+			if longPoll && !sleepContext(ctx, s.interval-time.Since(start)) {
+				return
+			}
+			continue
+		}
+		last = data
+
+		select {
+		case ch <- data:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nextPoll returns the server's max-age when present, otherwise the
+// configured interval
+func (s *HTTPSource[T]) nextPoll() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxAge > 0 {
+		return s.maxAge
+	}
+	return s.interval
+}
+
+// fetch performs a GET request. When conditional is set the last seen
+// validators are sent, and changed reports false for a 304 response.
+func (s *HTTPSource[T]) fetch(ctx context.Context, conditional bool, wait time.Duration) ([]byte, bool, error) {
+	req, err := s.newRequest(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if conditional {
+		s.mu.Lock()
+		if s.etag != "" {
+			req.Header.Set("If-None-Match", s.etag)
+		}
+		if s.lastModified != "" {
+			req.Header.Set("If-Modified-Since", s.lastModified)
+		}
+		s.mu.Unlock()
+	}
+	if wait > 0 {
+		req.Header.Set("Prefer", "wait="+strconv.Itoa(int(wait.Seconds())))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("request to %s failed: %w", s.name, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	s.mu.Lock()
+	s.maxAge = parseMaxAge(resp.Header.Get("Cache-Control"))
+	s.mu.Unlock()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, false, nil
+	case http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("unexpected status from %s: %s", s.name, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	s.mu.Unlock()
+
+	return data, true, nil
+}
+
+func (s *HTTPSource[T]) watchEvents(ctx context.Context, ch chan<- []byte) {
+	for {
+		retry, err := s.streamEvents(ctx, ch)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			LoggerFromContext(ctx).Warn("http event stream failed", "error", err)
+		}
+		if retry == 0 {
+			retry = s.interval
+		}
+		if !sleepContext(ctx, retry) {
+			return
+		}
+	}
+}
+
+// streamEvents consumes a single event stream connection until it ends. It
+// returns the reconnect delay requested by the server, if any.
+func (s *HTTPSource[T]) streamEvents(ctx context.Context, ch chan<- []byte) (time.Duration, error) {
+	req, err := s.newRequest(ctx)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	s.mu.Lock()
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+	s.mu.Unlock()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request to %s failed: %w", s.name, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status from %s: %s", s.name, resp.Status)
+	}
+
+	var (
+		retry time.Duration
+		data  bytes.Buffer
+		id    string
+	)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if data.Len() > 0 {
+				if id != "" {
+					s.mu.Lock()
+					s.lastEventID = id
+					s.mu.Unlock()
+				}
+				payload := bytes.Clone(data.Bytes())
+				select {
+				case ch <- payload:
+				case <-ctx.Done():
+					return retry, ctx.Err()
+				}
+			}
+			data.Reset()
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "id":
+			id = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	return retry, scanner.Err()
+}
+
+// LoadHistory loads the history retained by an AdminHandler serving the
+// source's URL, from GET <url>/history. The history is returned newest
+// first, with each value re-encoded as JSON. Like everything the handler
+// serves, the values are redacted, so they list changes rather than
+// restore them.
+func (s *HTTPSource[T]) LoadHistory(ctx context.Context, limit int) ([]SourceRevision, error) {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/history"
+
+	req, err := s.newRequestURL(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", s.name, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", s.name, resp.Status)
+	}
+
+	var entries []HistoryEntry[json.RawMessage]
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid history from %s: %w", s.name, err)
+	}
+
+	revisions := make([]SourceRevision, 0, len(entries))
+	for i := len(entries) - 1; i >= 0 && (limit == 0 || len(revisions) < limit); i-- {
+		revisions = append(revisions, SourceRevision{
+			Version:    strconv.FormatUint(entries[i].Version, 10),
+			ModifiedAt: entries[i].AppliedAt,
+			Data:       entries[i].Value,
+		})
+	}
+	return revisions, nil
+}
+
+func (s *HTTPSource[T]) newRequest(ctx context.Context) (*http.Request, error) {
+	return s.newRequestURL(ctx, s.url)
+}
+
+// newRequestURL creates an authenticated GET request for rawURL
+func (s *HTTPSource[T]) newRequestURL(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+
+	for _, auth := range s.auth {
+		if err := auth(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// parseMaxAge extracts max-age from a Cache-Control header. no-cache and
+// no-store disable caching and yield zero.
+func parseMaxAge(header string) time.Duration {
+	var maxAge time.Duration
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return maxAge
+}
+
+// sleepContext waits for d or until ctx is done. It reports whether the
+// full duration elapsed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
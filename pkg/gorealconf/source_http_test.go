@@ -0,0 +1,200 @@
+package gorealconf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPSource(t *testing.T) {
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+
+	t.Run("conditional polling", func(t *testing.T) {
+		var (
+			mu          sync.Mutex
+			version     = 1
+			notModified atomic.Int32
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			mu.Lock()
+			etag := fmt.Sprintf(`"v%d"`, version)
+			body := fmt.Sprintf(`{"value":"v%d"}`, version)
+			mu.Unlock()
+
+			if r.Header.Get("If-None-Match") == etag {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			fmt.Fprint(w, body)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		source, err := NewHTTPSource[TestConfig](server.URL,
+			WithHTTPAuth(BearerAuth("token")),
+			WithHTTPPollInterval(10*time.Millisecond),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		value, err := source.Load(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value.Value != "v1" {
+			t.Errorf("expected v1, got %q", value.Value)
+		}
+
+		changes, err := source.Watch(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for notModified.Load() == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		mu.Lock()
+		version = 2
+		mu.Unlock()
+
+		select {
+		case value := <-changes:
+			if value.Value != "v2" {
+				t.Errorf("expected v2, got %q", value.Value)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for change")
+		}
+	})
+
+	t.Run("server-sent events", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") != "text/event-stream" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 1\ndata: {\"value\":\ndata: \"sse\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		source, err := NewHTTPSource[TestConfig](server.URL, WithHTTPWatchMode(HTTPServerSentEvents))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		changes, err := source.Watch(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		select {
+		case value := <-changes:
+			if value.Value != "sse" {
+				t.Errorf("expected sse, got %q", value.Value)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for event")
+		}
+	})
+
+	t.Run("long poll ignored by server", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			fmt.Fprint(w, `{"value":"v1"}`)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		source, err := NewHTTPSource[TestConfig](server.URL,
+			WithHTTPWatchMode(HTTPLongPoll),
+			WithHTTPPollInterval(50*time.Millisecond),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		changes, err := source.Watch(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for range changes {
+		}
+
+		// 300ms at one request per 50ms, plus the initial fetch
+		if got := requests.Load(); got > 8 {
+			t.Errorf("expected at most 8 requests, got %d", got)
+		}
+	})
+
+	t.Run("errors without credentials", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		for _, base := range []string{server.URL, closed.URL} {
+			rawURL := strings.Replace(base, "http://", "http://user:s3cr3t@", 1) + "/config?token=s3cr3t"
+			source, err := NewHTTPSource[TestConfig](rawURL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err = source.Load(context.Background())
+			if err == nil {
+				t.Fatalf("expected an error from %s", base)
+			}
+			if strings.Contains(err.Error(), "s3cr3t") {
+				t.Errorf("error exposes credentials: %v", err)
+			}
+		}
+	})
+
+	t.Run("max-age", func(t *testing.T) {
+		tests := map[string]time.Duration{
+			"max-age=60":           time.Minute,
+			"public, max-age=5":    5 * time.Second,
+			"no-cache, max-age=60": 0,
+			"":                     0,
+		}
+		for header, want := range tests {
+			if got := parseMaxAge(header); got != want {
+				t.Errorf("parseMaxAge(%q) = %v, want %v", header, got, want)
+			}
+		}
+	})
+}
@@ -3,6 +3,7 @@ package gorealconf
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -35,24 +36,41 @@ func NewRedisSource[T any](addr, password, key, channel string) (*RedisSource[T]
 	}, nil
 }
 
+// Name identifies the source in metrics
+func (s *RedisSource[T]) Name() string {
+	return "redis:" + s.key
+}
+
 func (s *RedisSource[T]) Load(ctx context.Context) (T, error) {
-	data, err := s.client.Get(ctx, s.key).Bytes()
+	data, err := s.LoadRaw(ctx)
 	if err != nil {
 		var zero T
 		return zero, err
 	}
 
-	var value T
-	if err := json.Unmarshal(data, &value); err != nil {
-		var zero T
-		return zero, err
+	return decodeJSON[T](data)
+}
+
+func (s *RedisSource[T]) Watch(ctx context.Context) (<-chan T, error) {
+	raw, err := s.WatchRaw(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return value, nil
+	return decodeWatch[T](ctx, raw), nil
 }
 
-func (s *RedisSource[T]) Watch(ctx context.Context) (<-chan T, error) {
-	ch := make(chan T, 1)
+// LoadRaw loads the payload, which is nil when the key does not exist
+func (s *RedisSource[T]) LoadRaw(ctx context.Context) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *RedisSource[T]) WatchRaw(ctx context.Context) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
 	pubsub := s.client.Subscribe(ctx, s.channel)
 
 	go func() {
@@ -64,13 +82,10 @@ func (s *RedisSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 			case <-ctx.Done():
 				return
 			case msg := <-pubsub.Channel():
-				var newValue T
-				if err := json.Unmarshal([]byte(msg.Payload), &newValue); err == nil {
-					select {
-					case ch <- newValue:
-					case <-ctx.Done():
-						return
-					}
+				select {
+				case ch <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
 				}
 			}
 		}
@@ -78,3 +93,62 @@ func (s *RedisSource[T]) Watch(ctx context.Context) (<-chan T, error) {
 
 	return ch, nil
 }
+
+// LoadVersion loads the payload with a version derived from its content
+func (s *RedisSource[T]) LoadVersion(ctx context.Context) ([]byte, string, error) {
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentVersion(data), nil
+}
+
+// CompareAndSwap stores data in a transaction that fails if the key's
+// content changed, and publishes it to watchers
+func (s *RedisSource[T]) CompareAndSwap(ctx context.Context, version string, data []byte) (string, error) {
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, s.key).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if actual := contentVersion(current); actual != version {
+			return &CASConflictError{Expected: version, Actual: actual}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, s.key, data, 0)
+			pipe.Publish(ctx, s.channel, data)
+			return nil
+		})
+		return err
+	}, s.key)
+
+	switch {
+	case errors.Is(err, redis.TxFailedErr):
+		_, actual, _ := s.LoadVersion(ctx)
+		return "", &CASConflictError{Expected: version, Actual: actual}
+	case err != nil:
+		return "", err
+	}
+	return contentVersion(data), nil
+}
+
+// Close closes the Redis client
+func (s *RedisSource[T]) Close() error {
+	return s.client.Close()
+}
+
+// Save stores value under the key as JSON and publishes it to watchers
+func (s *RedisSource[T]) Save(ctx context.Context, value T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Set(ctx, s.key, data, 0).Err(); err != nil {
+		return err
+	}
+	return s.client.Publish(ctx, s.channel, data).Err()
+}
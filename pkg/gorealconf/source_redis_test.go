@@ -0,0 +1,107 @@
+package gorealconf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// serveEmptyRedis answers the RESP commands used by RedisSource as a Redis
+// server without any keys would, and returns its address
+func serveEmptyRedis(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					args, err := readRESPArray(r)
+					if err != nil {
+						return
+					}
+					reply := "+OK\r\n"
+					switch strings.ToUpper(args[0]) {
+					case "PING":
+						reply = "+PONG\r\n"
+					case "GET":
+						reply = "$-1\r\n"
+					}
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// readRESPArray reads a command sent as an array of bulk strings
+func readRESPArray(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	if _, err := fmt.Sscanf(line, "*%d", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if _, err := r.ReadString('\n'); err != nil { // $<length>
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(arg, "\r\n"))
+	}
+	return args, nil
+}
+
+func TestRedisSourceMissingKey(t *testing.T) {
+	type TestConfig struct {
+		Value string `json:"value"`
+	}
+
+	ctx := context.Background()
+	addr := serveEmptyRedis(t)
+
+	source, err := NewRedisSource[TestConfig](addr, "", "config", "config")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer source.Close()
+
+	data, err := source.LoadRaw(ctx)
+	if err != nil || data != nil {
+		t.Fatalf("expected no payload for a missing key, got %q, %v", data, err)
+	}
+
+	store, err := NewRedisSource[RolloutRecord[TestConfig]](addr, "", "rollout", "rollout")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	ok, err := NewRollout(New[TestConfig]()).WithStateStore(store).Restore(ctx)
+	if ok || err != nil {
+		t.Errorf("expected nothing to restore on first boot, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,24 @@
+package gorealconf
+
+import "context"
+
+// Subject identifies who a request is served for. ID is the stable key
+// used for bucketing, such as a user or tenant ID; Attributes carry
+// anything else rollouts may target on, such as region or tier.
+type Subject struct {
+	ID         string
+	Attributes map[string]string
+}
+
+type subjectKey struct{}
+
+// WithSubject returns a copy of ctx carrying subject
+func WithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext returns the subject carried by ctx, if any
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(Subject)
+	return subject, ok
+}
@@ -0,0 +1,97 @@
+package gorealconf
+
+import "context"
+
+// Names of the spans a Config emits
+const (
+	SpanLoad     = "gorealconf.load"     // initial load of a source
+	SpanDecode   = "gorealconf.decode"   // decoding a payload
+	SpanValidate = "gorealconf.validate" // running the validator
+	SpanApply    = "gorealconf.apply"    // applying an update
+	SpanNotify   = "gorealconf.notify"   // notifying subscribers of an update
+)
+
+// SpanAttributes describe the operation a span covers
+type SpanAttributes struct {
+	Config  string // the config's name, see WithName
+	Source  string // the source's name, see WithNamedSource
+	Version uint64 // the version applied, 0 when not known yet
+}
+
+// Tracer traces configuration operations. The otelconf package implements
+// it with OpenTelemetry.
+type Tracer interface {
+	// Start starts the span name as a child of ctx. The returned function
+	// ends it, recording err when it is not nil.
+	Start(ctx context.Context, name string, attrs SpanAttributes) (context.Context, func(err error))
+}
+
+type noTracer struct{}
+
+func (noTracer) Start(ctx context.Context, _ string, _ SpanAttributes) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+
+// WithTracer traces loads, decodes, validations, updates and subscriber
+// notifications
+func WithTracer[T any](tracer Tracer) Option[T] {
+	return func(c *Config[T]) {
+		if tracer == nil {
+			tracer = noTracer{}
+		}
+		c.tracer = tracer
+	}
+}
+
+// ChangeEvent describes an applied update
+type ChangeEvent[T any] struct {
+	Value   T
+	Version uint64
+	Source  string
+
+	// raw and previous are the value as received and the one it replaced,
+	// both before secret resolution
+	raw, previous T
+}
+
+// OnChange registers a listener called after every applied update. Its
+// context carries the update's trace, so work done in response shows up
+// in the same trace as the change that caused it.
+func (c *Config[T]) OnChange(listener func(ctx context.Context, event ChangeEvent[T])) {
+	c.onChange(listener)
+}
+
+// changeListener is a listener registered with OnChange. Listeners are
+// held by pointer so they can be told apart when removed.
+type changeListener[T any] struct {
+	fn func(ctx context.Context, event ChangeEvent[T])
+}
+
+// onChange registers listener and returns a function that removes it
+func (c *Config[T]) onChange(listener func(ctx context.Context, event ChangeEvent[T])) (remove func()) {
+	registered := &changeListener[T]{fn: listener}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, registered)
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		// Copy rather than filter in place, since updates deliver to a
+		// snapshot of the slice outside the lock
+		listeners := make([]*changeListener[T], 0, len(c.listeners))
+		for _, l := range c.listeners {
+			if l != registered {
+				listeners = append(listeners, l)
+			}
+		}
+		c.listeners = listeners
+	}
+}
+
+// startSpan starts a span for an operation on source
+func (c *Config[T]) startSpan(ctx context.Context, name, source string) (context.Context, func(error)) {
+	return c.tracer.Start(ctx, name, SpanAttributes{Config: c.name, Source: source})
+}
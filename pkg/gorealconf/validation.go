@@ -2,6 +2,8 @@ package gorealconf
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -36,3 +38,63 @@ func DefaultValidationOptions() ValidationOptions {
 		RetryDelay: time.Second,
 	}
 }
+
+// ValidationRule is a named check run by WithValidationRules. The name
+// identifies the rule in errors and logs.
+type ValidationRule[T any] struct {
+	Name  string
+	Check func(old, new T) error
+}
+
+// WithValidationRules validates updates with every rule. When any fail,
+// the validation error wraps a *RuleError naming them.
+func WithValidationRules[T any](rules ...ValidationRule[T]) Option[T] {
+	return WithValidation(func(old, new T) error {
+		var rerr RuleError
+		for _, rule := range rules {
+			if err := rule.Check(old, new); err != nil {
+				rerr.Failures = append(rerr.Failures, RuleFailure{Rule: rule.Name, Err: err})
+			}
+		}
+		if len(rerr.Failures) == 0 {
+			return nil
+		}
+		return &rerr
+	})
+}
+
+// RuleFailure is a validation rule that rejected an update
+type RuleFailure struct {
+	Rule string
+	Err  error
+}
+
+// RuleError reports the validation rules that rejected an update
+type RuleError struct {
+	Failures []RuleFailure
+}
+
+func (e *RuleError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = fmt.Sprintf("%s: %v", f.Rule, f.Err)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *RuleError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// Rules returns the names of the failed rules
+func (e *RuleError) Rules() []string {
+	names := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		names[i] = f.Rule
+	}
+	return names
+}
@@ -0,0 +1,150 @@
+package gorealconf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// stringVisitor returns the replacement for the string found at path.
+// secret reports whether the string sits in a field tagged
+// `secret:"true"` or in a Sensitive value.
+type stringVisitor func(path string, secret bool, s string) (string, error)
+
+// rewriteStrings returns a deep copy of value with every reachable string
+// passed through visit. The original value is never modified, so shared
+// slices, maps and pointers stay intact.
+func rewriteStrings[T any](value T, visit stringVisitor) (T, error) {
+	v := reflect.ValueOf(&value).Elem()
+	out, err := rewriteValue(v, "", false, visit)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return out.Interface().(T), nil
+}
+
+func rewriteValue(v reflect.Value, path string, secret bool, visit stringVisitor) (reflect.Value, error) {
+	// Sensitive keeps its value in an unexported field
+	if w, ok := asRewrappable(v); ok {
+		elem, err := rewriteValue(w.wrappedValue(), path, true, visit)
+		if err != nil {
+			return v, err
+		}
+		return reflect.ValueOf(w.rewrapValue(elem)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, err := visit(path, secret, v.String())
+		if err != nil {
+			return v, err
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(s)
+		return out, nil
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := rewriteValue(v.Elem(), path, secret, visit)
+		if err != nil {
+			return v, err
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(elem)
+		return out, nil
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := rewriteValue(v.Elem(), path, secret, visit)
+		if err != nil {
+			return v, err
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(elem)
+		return out, nil
+
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			elem, err := rewriteValue(v.Field(i), joinPath(path, f.Name), isSecretField(f), visit)
+			if err != nil {
+				return v, err
+			}
+			out.Field(i).Set(elem)
+		}
+		return out, nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := rewriteValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), secret, visit)
+			if err != nil {
+				return v, err
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			elem, err := rewriteValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), secret, visit)
+			if err != nil {
+				return v, err
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, err := rewriteValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), secret, visit)
+			if err != nil {
+				return v, err
+			}
+			out.SetMapIndex(iter.Key(), elem)
+		}
+		return out, nil
+	}
+
+	return v, nil
+}
+
+// rewrappable is implemented by Sensitive so that walkers can reach and
+// replace the value it wraps
+type rewrappable interface {
+	wrappedValue() reflect.Value
+	rewrapValue(v reflect.Value) any
+}
+
+func asRewrappable(v reflect.Value) (rewrappable, bool) {
+	if !v.IsValid() || v.Kind() != reflect.Struct || !v.CanInterface() {
+		return nil, false
+	}
+	w, ok := v.Interface().(rewrappable)
+	return w, ok
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}